@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// apiSnippetList returns a page of snippets as JSON, along with the
+// metadata needed to fetch the next and previous pages. It takes the same
+// query parameters as the /snippets page.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	q := readListQuery(r)
+	if !q.Valid() {
+		app.errorJSON(w, r, http.StatusBadRequest,
+			http.StatusText(http.StatusBadRequest), q.FieldErrors)
+		return
+	}
+
+	page, err := app.snippets.List(q.ListOptions)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.errorJSON(w, r, http.StatusBadRequest, "invalid pagination cursor", nil)
+		} else {
+			app.serverErrorJSON(w, r, err)
+		}
+		return
+	}
+
+	// Always return an array, even when there are no snippets, rather
+	// than null.
+	snippets := page.Snippets
+	if snippets == nil {
+		snippets = []models.Snippet{}
+	}
+
+	body := envelope{
+		"snippets": snippets,
+		"metadata": newPageMetadata(q, page),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, body, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// apiSnippetView returns a single snippet as JSON.
+func (app *application) apiSnippetView(w http.ResponseWriter, r *http.Request) {
+	snippet, moved, err := app.lookupSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.clientErrorJSON(w, r, http.StatusNotFound)
+		} else {
+			app.serverErrorJSON(w, r, err)
+		}
+		return
+	}
+
+	// Burn-after-reading snippets can only be read once, through the
+	// confirmation page in the browser. As with the raw endpoints, they're
+	// not found here, rather than giving away that the link is unread.
+	if snippet.BurnAfterReading {
+		app.clientErrorJSON(w, r, http.StatusNotFound)
+		return
+	}
+
+	// Like the raw endpoints, the read-only API doesn't know who's asking,
+	// so private and password-protected snippets stay in the browser.
+	switch snippet.Visibility {
+	case models.VisibilityPrivate:
+		app.clientErrorJSON(w, r, http.StatusNotFound)
+		return
+	case models.VisibilityPassword:
+		app.errorJSON(w, r, http.StatusForbidden,
+			"this snippet is password-protected and can only be viewed in the browser", nil)
+		return
+	}
+
+	// Old numeric URLs are only redirected once we know the snippet can be
+	// shown, so that the redirect doesn't give away the slug of a private
+	// one.
+	if moved {
+		redirectToSlug(w, r, snippet.Slug)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"snippet": snippet}, nil)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// apiSnippetCreate creates a snippet from a JSON body like
+// {"title": "...", "content": "...", "expires": 7, "burn_after_reading": false,
+// "tags": ["sql"], "language": "sql", "format": "code", "visibility": "public"}.
+// Password-protected snippets also need a "password". It runs the same
+// validation as the HTML form.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title            string   `json:"title"`
+		Content          string   `json:"content"`
+		Expires          int      `json:"expires"`
+		BurnAfterReading bool     `json:"burn_after_reading"`
+		Tags             []string `json:"tags"`
+		Language         string   `json:"language"`
+		Format           string   `json:"format"`
+		Visibility       string   `json:"visibility"`
+		Password         string   `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	form := snippetCreateForm{
+		Title:   input.Title,
+		Content: input.Content,
+		Expires: input.Expires,
+
+		BurnAfterReading: input.BurnAfterReading,
+		Tags:             parseTags(strings.Join(input.Tags, ",")),
+		Language:         input.Language,
+		Format:           input.Format,
+		Visibility:       input.Visibility,
+		Password:         input.Password,
+	}
+
+	// Snippets are public code unless the client says otherwise.
+	if form.Format == "" {
+		form.Format = models.FormatCode
+	}
+	if form.Visibility == "" {
+		form.Visibility = models.VisibilityPublic
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		app.errorJSON(w, r, http.StatusUnprocessableEntity,
+			http.StatusText(http.StatusUnprocessableEntity), form.FieldErrors)
+		return
+	}
+
+	slug, err := app.snippets.Insert(form.newSnippet(app.authenticatedUserID(r)))
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	snippet, err := app.snippets.GetBySlug(slug)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", "/api/v1/snippets/"+slug)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"snippet": snippet}, headers)
+	if err != nil {
+		app.serverErrorJSON(w, r, err)
+	}
+}
+
+// apiNotFound catches any other request under /api/, so that clients always
+// get a JSON error rather than the mux's plain-text 404.
+func (app *application) apiNotFound(w http.ResponseWriter, r *http.Request) {
+	app.clientErrorJSON(w, r, http.StatusNotFound)
+}
@@ -0,0 +1,12 @@
+package main
+
+// Define a custom contextKey type so that the keys we store in the request
+// context can't collide with keys set by other packages.
+type contextKey string
+
+const (
+	isAuthenticatedContextKey     = contextKey("isAuthenticated")
+	authenticatedUserIDContextKey = contextKey("authenticatedUserID")
+	clientIPContextKey            = contextKey("clientIP")
+	requestIDContextKey           = contextKey("requestID")
+)
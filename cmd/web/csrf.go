@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+)
+
+// We use the synchronizer token pattern: each session gets a random CSRF
+// token, and every state-changing request must send it back in the
+// csrf_token form field (or the X-CSRF-Token header). The token handed to
+// templates is masked with a fresh one-time pad on every render, so the
+// page never contains the same bytes twice (this defeats BREACH-style
+// compression attacks).
+const (
+	csrfTokenLength    = 32
+	csrfSessionKey     = "csrfToken"
+	csrfFormField      = "csrf_token"
+	csrfHeader         = "X-CSRF-Token"
+	csrfMaskedTokenLen = csrfTokenLength * 2
+)
+
+// safeMethods are the methods which must not change state, so they don't
+// need a CSRF check.
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// csrfToken returns the raw CSRF token for the current session, creating
+// and storing a new one if the session doesn't have one yet.
+func (app *application) csrfToken(r *http.Request) ([]byte, error) {
+	token := app.sessionManager.GetBytes(r.Context(), csrfSessionKey)
+	if len(token) == csrfTokenLength {
+		return token, nil
+	}
+
+	token = make([]byte, csrfTokenLength)
+	_, err := rand.Read(token)
+	if err != nil {
+		return nil, err
+	}
+
+	app.sessionManager.Put(r.Context(), csrfSessionKey, token)
+
+	return token, nil
+}
+
+// maskCSRFToken XORs the token with a random pad and returns the pad and
+// the result together, base64 encoded.
+func maskCSRFToken(token []byte) (string, error) {
+	masked := make([]byte, csrfMaskedTokenLen)
+
+	pad := masked[:csrfTokenLength]
+	_, err := rand.Read(pad)
+	if err != nil {
+		return "", err
+	}
+
+	for i := range token {
+		masked[csrfTokenLength+i] = pad[i] ^ token[i]
+	}
+
+	return base64.RawURLEncoding.EncodeToString(masked), nil
+}
+
+// unmaskCSRFToken reverses maskCSRFToken. It returns nil if the value isn't
+// a well-formed masked token.
+func unmaskCSRFToken(value string) []byte {
+	masked, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(masked) != csrfMaskedTokenLen {
+		return nil
+	}
+
+	token := make([]byte, csrfTokenLength)
+	for i := range token {
+		token[i] = masked[i] ^ masked[csrfTokenLength+i]
+	}
+
+	return token
+}
+
+// sameOrigin uses the Sec-Fetch-Site and Origin headers set by browsers to
+// check that a request came from one of our own pages. It only returns false
+// when a header positively says the request is cross-origin; requests from
+// clients which send neither header are left to the token check.
+func sameOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "cross-site", "same-site":
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	return u.Host == r.Host
+}
+
+// validCSRFToken checks the token sent with the request against the one in
+// the session, in constant time.
+func (app *application) validCSRFToken(r *http.Request, token []byte) bool {
+	value := r.Header.Get(csrfHeader)
+	if value == "" {
+		value = r.PostFormValue(csrfFormField)
+	}
+
+	sent := unmaskCSRFToken(value)
+	if sent == nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(sent, token) == 1
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaskCSRFToken(t *testing.T) {
+	token := bytes.Repeat([]byte{0x5a}, csrfTokenLength)
+
+	first, err := maskCSRFToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := maskCSRFToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Error("masking the same token twice gave the same value")
+	}
+
+	for _, masked := range []string{first, second} {
+		if got := unmaskCSRFToken(masked); !bytes.Equal(got, token) {
+			t.Errorf("unmaskCSRFToken(%q) = %x; want %x", masked, got, token)
+		}
+	}
+}
+
+func TestUnmaskCSRFToken(t *testing.T) {
+	valid, err := maskCSRFToken(bytes.Repeat([]byte{1}, csrfTokenLength))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"Empty", ""},
+		{"Not base64", "not a token!"},
+		{"Too short", valid[:len(valid)-4]},
+		{"Too long", valid + "AAAA"},
+		{"Unmasked token", base64.RawURLEncoding.EncodeToString(make([]byte, csrfTokenLength))},
+		{"Padded base64", base64.URLEncoding.EncodeToString(make([]byte, csrfMaskedTokenLen))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unmaskCSRFToken(tt.value); got != nil {
+				t.Errorf("got %x; want nil", got)
+			}
+		})
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"No headers", nil, true},
+		{"Same origin fetch", map[string]string{"Sec-Fetch-Site": "same-origin"}, true},
+		{"Typed into the address bar", map[string]string{"Sec-Fetch-Site": "none"}, true},
+		{"Cross-site fetch", map[string]string{"Sec-Fetch-Site": "cross-site"}, false},
+		{"Same-site fetch", map[string]string{"Sec-Fetch-Site": "same-site"}, false},
+		{"Matching origin", map[string]string{"Origin": "http://example.com"}, true},
+		{"Other origin", map[string]string{"Origin": "https://evil.example"}, false},
+		{"Opaque origin", map[string]string{"Origin": "null"}, false},
+		{"Sec-Fetch-Site wins", map[string]string{"Sec-Fetch-Site": "same-origin", "Origin": "https://evil.example"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://example.com/snippet/create", strings.NewReader(""))
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			if got := sameOrigin(r); got != tt.want {
+				t.Errorf("got %t; want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/fatonh/lovrinbox/internal/diff"
+	"github.com/fatonh/lovrinbox/internal/highlight"
 	"github.com/fatonh/lovrinbox/internal/models"
+	"github.com/fatonh/lovrinbox/internal/validator"
 )
 
 // change the signature of the home handler function
@@ -21,11 +25,19 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Fetch the 30 most used tags for the tag cloud.
+	tags, err := app.snippets.Tags(30)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Call the newTemplateData() helper to get a templateData struct containing
 	// the 'default' data (which for now is just the current year)
 	// and then add the snippets slice to it.
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.TagCloud = newTagCloud(tags)
 
 	// Use the new render helper method.
 	app.render(w, r, http.StatusOK, "home.tmpl",
@@ -70,30 +82,151 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// w.Write([]byte("Welcome to the Home Page!"))
 }
 
-// Add a snippetView handler function.
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	id, err := strconv.Atoi(r.PathValue("id"))
-	if err != nil || id < 1 {
+// snippetList shows every unexpired snippet, a page at a time, sorted by
+// the ?sort= parameter.
+func (app *application) snippetList(w http.ResponseWriter, r *http.Request) {
+	q := readListQuery(r)
+	if !q.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.List(q.ListOptions)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.clientError(w, r, http.StatusBadRequest)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = page.Snippets
+	data.Page = newPageMetadata(q, page)
+
+	app.render(w, r, http.StatusOK, "list.tmpl", data)
+}
+
+// snippetTag lists the snippets with the tag in the URL, in the same way as
+// snippetList.
+func (app *application) snippetTag(w http.ResponseWriter, r *http.Request) {
+	tag := r.PathValue("name")
+	if !validTag(tag) {
 		http.NotFound(w, r)
 		return
 	}
 
-	// use the SnippetModel's Get() method to retrieve the
-	// the data for a specific record based on it's ID.
-	// If no matching record is found. return a 404 Not found response.
-	snippet, err := app.snippets.Get(id)
+	q := readListQuery(r)
+	q.Tag = tag
+	if !q.Valid() {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.List(q.ListOptions)
 	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			http.NotFound(w, r)
+		if errors.Is(err, models.ErrInvalidCursor) {
+			app.clientError(w, r, http.StatusBadRequest)
 		} else {
 			app.serverError(w, r, err)
 		}
 		return
 	}
 
+	data := app.newTemplateData(r)
+	data.Snippets = page.Snippets
+	data.Page = newPageMetadata(q, page)
+
+	app.render(w, r, http.StatusOK, "list.tmpl", data)
+}
+
+// snippetSearch shows the snippets matching the ?q= query, a page at a time.
+// Without a query it just shows the search form.
+func (app *application) snippetSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	page := queryInt(r, "page", 1)
+	if page < 1 || !validator.MaxChars(query, 200) {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Search = searchData{
+		Query: query,
+		Terms: models.SearchTerms(query),
+	}
+
+	if query != "" {
+		results, err := app.snippets.Search(query, page)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		data.Snippets = results.Snippets
+		data.Search.PrevPage = page - 1
+		if results.HasNext {
+			data.Search.NextPage = page + 1
+		}
+	}
+
+	app.render(w, r, http.StatusOK, "search.tmpl", data)
+}
+
+// Add a snippetView handler function.
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	// use the resolveSnippet() helper to retrieve the data for the
+	// snippet named by the slug (or old numeric ID) in the URL. If no
+	// matching record is found, it sends a 404 Not found response.
+	snippet, ok := app.resolveSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Private snippets are a 404 for everyone but their owner, so that
+	// nobody else can even tell they exist.
+	if !snippet.VisibleTo(app.authenticatedUserID(r)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Only public snippets should end up in caches and search engines.
+	if snippet.Visibility != models.VisibilityPublic {
+		noStore(w)
+	}
+
+	// Password-protected snippets ask for the password until the session
+	// has unlocked them.
+	if !app.canRead(r, snippet) {
+		app.renderUnlock(w, r, http.StatusOK, snippetUnlockForm{Slug: snippet.Slug})
+		return
+	}
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
 
+	// Burn-after-reading snippets get a confirmation page instead. Link
+	// previews and crawlers only ever make GET requests, so making the
+	// reader press a button (which POSTs to snippetBurnPost) stops them
+	// from using up the one and only view.
+	if snippet.BurnAfterReading {
+		data.Snippet = models.Snippet{Slug: snippet.Slug}
+		noStore(w)
+		app.render(w, r, http.StatusOK, "burn.tmpl", data)
+		return
+	}
+
+	if snippet.Format == models.FormatMarkdown {
+		var err error
+		data.Rendered, err = app.renderMarkdown(snippet)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
 	// use the new render helper.
 	app.render(w, r, http.StatusOK, "view.tmpl",
 		data)
@@ -130,21 +263,528 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 	// fmt.Fprintf(w, "%+v", snippet)
 }
 
+// maxContentBytes caps the size of a snippet's content at what fits in the
+// TEXT content columns of the MySQL schema. It's well below the request body
+// limits of the API and the paste endpoint, so that too much content gets a
+// field error there rather than a bare 413.
+const maxContentBytes = 65_535
+
+// Define a snippetCreateForm struct to represent the form data and validation
+// errors for the form fields. Note that all the struct fields are deliberately
+// exported (i.e. start with a capital letter). This is because struct fields
+// must be exported in order to be read by the html/template package when
+// rendering the template.
+type snippetCreateForm struct {
+	Title            string
+	Content          string
+	Expires          int
+	BurnAfterReading bool
+	Tags             []string
+	// Language is empty when the user wants it detected automatically.
+	Language string
+	Format   string
+	// Visibility is one of models.Visibilities. Password is only used by
+	// password-protected snippets.
+	Visibility string
+	Password   string
+	validator.Validator
+}
+
+// validate runs the validation checks for a new snippet. It's shared by the
+// HTML form and the JSON API, so both accept exactly the same input.
+func (form *snippetCreateForm) validate() {
+	// Because the Validator struct is embedded by the snippetCreateForm struct,
+	// we can call CheckField() directly on it to execute our validation checks.
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxBytes(form.Content, maxContentBytes), "content",
+		fmt.Sprintf("This field cannot be more than %d bytes long", maxContentBytes))
+	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.CheckField(validator.PermittedValue(form.Format, models.Formats...), "format", "This field must equal plain, code or markdown")
+	form.CheckField(form.Language == "" || highlight.Known(form.Language), "language", "This field must be one of the listed languages")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.Visibilities...), "visibility", "This field must equal public, unlisted, private or password")
+	if form.Visibility == models.VisibilityPassword {
+		form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+		form.CheckField(validator.MaxBytes(form.Password, models.MaxPasswordBytes), "password",
+			fmt.Sprintf("This field cannot be more than %d bytes long", models.MaxPasswordBytes))
+	}
+	form.CheckField(len(form.Tags) <= maxTags, "tags", fmt.Sprintf("This field cannot have more than %d tags", maxTags))
+
+	for _, tag := range form.Tags {
+		form.CheckField(validTag(tag), "tags",
+			fmt.Sprintf("Tags can only contain a-z, 0-9, '+', '.', '_' and '-', and be up to %d characters long", maxTagLength))
+	}
+}
+
+// newSnippet returns the snippet to insert for a valid form, owned by the
+// given user. If no language was picked, it's guessed from the content.
+func (form *snippetCreateForm) newSnippet(userID int) models.NewSnippet {
+	language := form.Language
+	if language == "" {
+		if form.Format == models.FormatMarkdown {
+			language = "markdown"
+		} else {
+			language = highlight.Detect(form.Content)
+		}
+	}
+
+	return models.NewSnippet{
+		UserID:           userID,
+		Title:            form.Title,
+		Content:          form.Content,
+		Expires:          form.Expires,
+		BurnAfterReading: form.BurnAfterReading,
+		Tags:             form.Tags,
+		Language:         language,
+		Format:           form.Format,
+		Visibility:       form.Visibility,
+		Password:         form.Password,
+	}
+}
+
+// snippetBurnPost shows a burn-after-reading snippet once the reader has
+// confirmed, deleting it at the same time. If two people confirm at once
+// only one of them gets the snippet; the other gets a 404.
+func (app *application) snippetBurnPost(w http.ResponseWriter, r *http.Request) {
+	// Check the snippet's visibility and password before burning it.
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	snippet, err := app.snippets.Burn(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Flash = "This snippet has now been deleted. Copy anything you need before leaving this page."
+
+	if snippet.Format == models.FormatMarkdown {
+		data.Rendered, err = app.renderMarkdown(snippet)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	noStore(w)
+	app.render(w, r, http.StatusOK, "view.tmpl", data)
+}
+
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("Create a new snippet lovrin..."))
+	data := app.newTemplateData(r)
+
+	// Initialize a new snippetCreateForm instance and pass it to the template.
+	// This lets us set the default value of the expiry radio buttons to
+	// 365 days, and means the template doesn't have to handle a nil Form.
+	data.Form = snippetCreateForm{
+		Expires:    365,
+		Format:     models.FormatCode,
+		Visibility: models.VisibilityPublic,
+	}
+
+	app.render(w, r, http.StatusOK, "create.tmpl", data)
 }
 
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
-	title := "O snail"
-	content := "O snail\nClimb Mount Fuji,\nBut slowly, slowly!\n\n– Kobayashi Issa"
-	expires := 7
+	// First we call r.ParseForm() which adds any data in POST request bodies
+	// to the r.PostForm map. If there are any errors, we use our
+	// app.clientError() helper to send a 400 Bad Request response.
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	// The r.PostForm.Get() method always returns the form data as a *string*.
+	// However, we're expecting our expires value to be a number, so we
+	// manually convert the form data to an integer using strconv.Atoi().
+	// Anything which isn't a number is left as 0, which the validation
+	// below rejects with a field error like any other bad value.
+	expires, err := strconv.Atoi(r.PostForm.Get("expires"))
+	if err != nil {
+		expires = 0
+	}
+
+	form := snippetCreateForm{
+		Title:   r.PostForm.Get("title"),
+		Content: r.PostForm.Get("content"),
+		Expires: expires,
+		// An unchecked checkbox isn't sent at all, so we just check for
+		// the value a checked one sends.
+		BurnAfterReading: r.PostForm.Get("burn") == "true",
+		Tags:             parseTags(r.PostForm.Get("tags")),
+		Language:         r.PostForm.Get("language"),
+		Format:           r.PostForm.Get("format"),
+		Visibility:       r.PostForm.Get("visibility"),
+		Password:         r.PostForm.Get("password"),
+	}
+
+	form.validate()
+
+	// If there are any validation errors, then re-display the create.tmpl
+	// template, passing in the snippetCreateForm instance as dynamic data in
+	// the Form field. Note that we use the HTTP status code 422 Unprocessable
+	// Entity when sending the response to indicate that there was a
+	// validation error.
+	if !form.Valid() {
+		// Don't send the password back to the browser.
+		form.Password = ""
+
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "create.tmpl", data)
+		return
+	}
+
+	slug, err := app.snippets.Insert(form.newSnippet(app.authenticatedUserID(r)))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Use the Put() method to add a string value ("Snippet successfully
+	// created!") and the corresponding key ("flash") to the session data.
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
+
+	http.Redirect(w, r, "/snippet/view/"+slug, http.StatusSeeOther)
+}
+
+// snippetEditForm holds the form data for editing a snippet. Only the title
+// and content can change; the expiry stays as it was.
+type snippetEditForm struct {
+	Slug    string
+	Title   string
+	Content string
+	validator.Validator
+}
+
+// validate runs the same title and content checks as snippetCreateForm.
+func (form *snippetEditForm) validate() {
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxBytes(form.Content, maxContentBytes), "content",
+		fmt.Sprintf("This field cannot be more than %d bytes long", maxContentBytes))
+}
+
+// ownSnippet fetches the snippet with the ID in the URL and checks that it
+// belongs to the authenticated user. If not, it sends the appropriate error
+// response and returns false. Burn-after-reading snippets can't be edited.
+func (app *application) ownSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := app.resolveSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if snippet.BurnAfterReading {
+		http.NotFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	if snippet.UserID == 0 || snippet.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, r, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = snippetEditForm{
+		Slug:    snippet.Slug,
+		Title:   snippet.Title,
+		Content: snippet.Content,
+	}
+
+	app.render(w, r, http.StatusOK, "edit.tmpl", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := snippetEditForm{
+		Slug:    snippet.Slug,
+		Title:   r.PostForm.Get("title"),
+		Content: r.PostForm.Get("content"),
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	// Update() keeps the previous version in the revision history.
+	err = app.snippets.Update(snippet.ID, form.Title, form.Content)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+}
+
+// snippetRevisions lists every revision of a snippet and shows a line-based
+// diff between two of them, picked with the "from" and "to" query string
+// parameters. By default it compares the latest revision with the one
+// before it.
+func (app *application) snippetRevisions(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if snippet.Visibility != models.VisibilityPublic {
+		noStore(w)
+	}
+
+	// Burn-after-reading snippets can only be seen through snippetBurnPost.
+	if snippet.BurnAfterReading {
+		http.NotFound(w, r)
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	latest := revisions[len(revisions)-1].Revision
+
+	from := queryInt(r, "from", max(latest-1, 1))
+	to := queryInt(r, "to", latest)
+
+	// Revisions are numbered from 1 with no gaps, so revision n is at
+	// index n-1.
+	if from < 1 || from > latest || to < 1 || to > latest {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+	data.DiffFrom = from
+	data.DiffTo = to
+
+	// Revisions which differ too much aren't diffed, so that a large
+	// rewrite can't tie up the server. The page says so instead.
+	data.Diff, err = diff.Lines(revisions[from-1].Content, revisions[to-1].Content)
+	if err != nil {
+		if !errors.Is(err, diff.ErrTooLarge) {
+			app.serverError(w, r, err)
+			return
+		}
+		data.DiffTooLarge = true
+	}
+
+	app.render(w, r, http.StatusOK, "revisions.tmpl", data)
+}
+
+// Create a new userSignupForm struct.
+type userSignupForm struct {
+	Name     string
+	Email    string
+	Password string
+	validator.Validator
+}
+
+func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userSignupForm{}
+	app.render(w, r, http.StatusOK, "signup.tmpl", data)
+}
+
+func (app *application) userSignupPost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := userSignupForm{
+		Name:     r.PostForm.Get("name"),
+		Email:    r.PostForm.Get("email"),
+		Password: r.PostForm.Get("password"),
+	}
+
+	// Validate the form contents using our helper functions.
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+	form.CheckField(validator.MaxBytes(form.Password, models.MaxPasswordBytes), "password",
+		fmt.Sprintf("This field cannot be more than %d bytes long", models.MaxPasswordBytes))
 
-	id, err := app.snippets.Insert(title, content, expires)
+	// If there are any errors, redisplay the signup form along with a 422
+	// status code.
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		return
+	}
+
+	// Try to create a new user record in the database. If the email already
+	// exists then add an error message to the form and re-display it.
+	err = app.users.Insert(form.Name, form.Email, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateEmail) {
+			form.AddFieldError("email", "Email address is already in use")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "signup.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+
+		return
+	}
+
+	// Otherwise add a confirmation flash message to the session confirming that
+	// their signup worked.
+	app.sessionManager.Put(r.Context(), "flash", "Your signup was successful. Please log in.")
+
+	// And redirect the user to the login page.
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// Create a new userLoginForm struct.
+type userLoginForm struct {
+	Email    string
+	Password string
+	validator.Validator
+}
+
+func (app *application) userLogin(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = userLoginForm{}
+	app.render(w, r, http.StatusOK, "login.tmpl", data)
+}
+
+func (app *application) userLoginPost(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := userLoginForm{
+		Email:    r.PostForm.Get("email"),
+		Password: r.PostForm.Get("password"),
+	}
+
+	// Do some validation checks on the form. We check that both email and
+	// password are provided, and also check the format of the email address as
+	// a UX-nicety (in case the user makes a typo).
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
+		return
+	}
+
+	// Check whether the credentials are valid. If they're not, add a generic
+	// non-field error message and re-display the login page.
+	id, err := app.users.Authenticate(form.Email, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldError("Email or password is incorrect")
+
+			data := app.newTemplateData(r)
+			data.Form = form
+			app.render(w, r, http.StatusUnprocessableEntity, "login.tmpl", data)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	// Use the RenewToken() method on the current session to change the session
+	// ID. It's good practice to generate a new session ID when the
+	// authentication state or privilege levels changes for the user (e.g. login
+	// and logout operations), to prevent session fixation attacks.
+	err = app.sessionManager.RenewToken(r.Context())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	// Add the ID of the current user to the session, so that they are now
+	// 'logged in'.
+	app.sessionManager.Put(r.Context(), "authenticatedUserID", id)
+
+	// If requireAuthentication stored the page the user was trying to reach,
+	// send them back there. Otherwise redirect them to the create snippet page.
+	path := app.sessionManager.PopString(r.Context(), "redirectPathAfterLogin")
+	if path == "" {
+		path = "/snippet/create"
+	}
+
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
+	// Use the RenewToken() method on the current session to change the session
+	// ID again.
+	err := app.sessionManager.RenewToken(r.Context())
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id),
-		http.StatusSeeOther)
+	// Remove the authenticatedUserID from the session data so that the user is
+	// 'logged out'.
+	app.sessionManager.Remove(r.Context(), "authenticatedUserID")
+
+	// Add a flash message to the session to confirm to the user that they've been
+	// logged out.
+	app.sessionManager.Put(r.Context(), "flash", "You've been logged out successfully!")
+
+	// Redirect the user to the application home page.
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
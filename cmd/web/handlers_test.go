@@ -0,0 +1,140 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+func TestSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	public, err := app.snippets.Insert(models.NewSnippet{
+		Title: "An old silent pond", Content: "A frog jumps into the pond", Expires: 7,
+		Format: models.FormatPlain, Visibility: models.VisibilityPublic,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private, err := app.snippets.Insert(models.NewSnippet{
+		Title: "Secret", Content: "Not for you", Expires: 7, UserID: 1,
+		Format: models.FormatPlain, Visibility: models.VisibilityPrivate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := app.snippets.GetBySlug(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid slug", "/snippet/view/" + public, http.StatusOK, "A frog jumps into the pond"},
+		{"Unknown slug", "/snippet/view/AAAAAAAAAA", http.StatusNotFound, ""},
+		{"Numeric ID", "/snippet/view/" + strconv.Itoa(s.ID), http.StatusNotFound, ""},
+		{"Negative ID", "/snippet/view/-1", http.StatusNotFound, ""},
+		{"String", "/snippet/view/foo", http.StatusNotFound, ""},
+		{"Empty", "/snippet/view/", http.StatusNotFound, ""},
+		{"Private snippet", "/snippet/view/" + private, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+
+			if tt.wantBody != "" && !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/snippet/create")
+
+		if code != http.StatusSeeOther {
+			t.Errorf("got status %d; want %d", code, http.StatusSeeOther)
+		}
+		if got := header.Get("Location"); got != "/user/login" {
+			t.Errorf("got Location %q; want %q", got, "/user/login")
+		}
+	})
+
+	ts.login(t, app, "alice@example.com", "pa$$word")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		title     string
+		content   string
+		expires   string
+		csrfToken string
+		wantCode  int
+		wantBody  string
+	}{
+		{"Valid submission", "Haiku", "An old silent pond", "7", csrfToken, http.StatusSeeOther, ""},
+		{"Blank title", "", "An old silent pond", "7", csrfToken, http.StatusUnprocessableEntity, "This field cannot be blank"},
+		{"Longest content", "Haiku", strings.Repeat("a", maxContentBytes), "7", csrfToken, http.StatusSeeOther, ""},
+		{"Content too long", "Haiku", strings.Repeat("a", maxContentBytes+1), "7", csrfToken, http.StatusUnprocessableEntity, "This field cannot be more than 65535 bytes long"},
+		{"Multi-byte content too long", "Haiku", strings.Repeat("é", maxContentBytes/2+1), "7", csrfToken, http.StatusUnprocessableEntity, "This field cannot be more than 65535 bytes long"},
+		{"Invalid expiry", "Haiku", "An old silent pond", "never", csrfToken, http.StatusUnprocessableEntity, "This field must equal 1, 7 or 365"},
+		{"Wrong CSRF token", "Haiku", "An old silent pond", "7", "wrongToken", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", tt.expires)
+			form.Add("format", models.FormatPlain)
+			form.Add("visibility", models.VisibilityPublic)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+
+			if tt.wantBody != "" && !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+
+			if code == http.StatusSeeOther {
+				location := header.Get("Location")
+				slug, ok := strings.CutPrefix(location, "/snippet/view/")
+				if !ok || !models.ValidSlug(slug) {
+					t.Fatalf("got Location %q; want /snippet/view/<slug>", location)
+				}
+
+				_, _, body := ts.get(t, location)
+				if !strings.Contains(body, tt.content) {
+					t.Errorf("want created snippet to contain %q", tt.content)
+				}
+			}
+		})
+	}
+}
@@ -2,14 +2,21 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func (app *application) serverError(w http.ResponseWriter,
-	r *http.Request, err error) {
+// logServerError logs an unexpected error along with the request details and
+// a stack trace.
+func (app *application) logServerError(r *http.Request, err error) {
 	var (
 		method = r.Method
 		uri    = r.URL.RequestURI()
@@ -19,8 +26,13 @@ func (app *application) serverError(w http.ResponseWriter,
 		trace = string(debug.Stack())
 	)
 
-	app.logger.Error(err.Error(), "method", method,
+	app.logger.ErrorContext(r.Context(), err.Error(), "ip", clientIP(r), "method", method,
 		"uri", uri, "trace", trace)
+}
+
+func (app *application) serverError(w http.ResponseWriter,
+	r *http.Request, err error) {
+	app.logServerError(r, err)
 
 	http.Error(w,
 		http.StatusText(http.StatusInternalServerError),
@@ -40,7 +52,20 @@ func (app *application) render(w http.ResponseWriter, r *http.Request,
 	// name (like 'home.tmpl'). If no entry exists in the cache with the
 	// provided name, then create a new error and call the serverError() helper
 	// method that we made earlier and return.
-	ts, ok := app.templateCache[page]
+	cache := app.templateCache
+
+	// In -dev mode we re-parse the templates from disk on every request, so
+	// that edits show up without restarting the server.
+	if app.devMode {
+		var err error
+		cache, err = newTemplateCache(app.uiFS)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	ts, ok := cache[page]
 	if !ok {
 		err := fmt.Errorf("the tempalte %s does not exist", page)
 		app.serverError(w, r, err)
@@ -77,11 +102,204 @@ func (app *application) render(w http.ResponseWriter, r *http.Request,
 	// }
 }
 
+// queryInt reads an integer from the URL query string, returning def if
+// the parameter is missing. A value which isn't an integer returns -1, so
+// that callers' range checks reject it.
+func queryInt(r *http.Request, key string, def int) int {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
+
+// clientIP returns the IP address of the client, without the port, as
+// resolved by the realIP middleware through any trusted proxies. Outside
+// that middleware it falls back to the address of the peer.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey).(string); ok {
+		return ip
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// noStore stops browsers and proxies from keeping a copy of the response,
+// and asks search engines not to index it.
+func noStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+}
+
 // Create a newTemplateData() helper. which returns a templateData struct
-// initialized with the current year. Note that we're not using the *http.Request
-// parameter here at the moment, but we will use it later in the book.
+// initialized with the current year, any flash message in the session and a
+// freshly masked CSRF token for the page's forms. PopString() removes the
+// flash from the session as it reads it, so it's only ever rendered once.
 func (app *application) newTemplateData(r *http.Request) templateData {
 	return templateData{
-		CurrentYear: time.Now().Year(),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           app.maskedCSRFToken(r),
+	}
+}
+
+// maskedCSRFToken returns the session's CSRF token, masked so it's safe to
+// embed in a page. It returns an empty string if the token can't be
+// created, in which case any form on the page will fail the CSRF check.
+func (app *application) maskedCSRFToken(r *http.Request) string {
+	token, err := app.csrfToken(r)
+	if err != nil {
+		return ""
+	}
+
+	masked, err := maskCSRFToken(token)
+	if err != nil {
+		return ""
+	}
+
+	return masked
+}
+
+// authenticatedUserID returns the ID of the authenticated user making the
+// request, or 0 if there isn't one.
+func (app *application) authenticatedUserID(r *http.Request) int {
+	id, _ := r.Context().Value(authenticatedUserIDContextKey).(int)
+	return id
+}
+
+// Return true if the current request is from an authenticated user,
+// otherwise return false. The value is set in the request context by the
+// authenticate middleware.
+func (app *application) isAuthenticated(r *http.Request) bool {
+	isAuthenticated, ok := r.Context().Value(isAuthenticatedContextKey).(bool)
+	if !ok {
+		return false
+	}
+
+	return isAuthenticated
+}
+
+// maxJSONBytes caps the size of request bodies accepted by the JSON API.
+const maxJSONBytes = 1_048_576
+
+// envelope wraps the top-level value of every JSON response, e.g.
+// {"snippet": {...}} or {"error": {...}}.
+type envelope map[string]any
+
+// apiError is the body of the "error" envelope returned by the JSON API.
+type apiError struct {
+	Status  int               `json:"status"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// writeJSON encodes data as JSON and writes it with the given status code.
+// The body is encoded before anything is written, so that an encoding error
+// can still be reported as a 500.
+func (app *application) writeJSON(w http.ResponseWriter, status int,
+	data envelope, headers http.Header) error {
+	js, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
 	}
+	js = append(js, '\n')
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// readJSON decodes a single JSON value from the request body into dst. The
+// body is capped at maxJSONBytes, and unknown fields are rejected so that
+// typos in field names don't go unnoticed. The returned errors are safe to
+// show to the client.
+func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var (
+			syntaxError        *json.SyntaxError
+			unmarshalTypeError *json.UnmarshalTypeError
+			maxBytesError      *http.MaxBytesError
+		)
+
+		switch {
+		case errors.As(err, &syntaxError):
+			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("body contains badly-formed JSON")
+		case errors.As(err, &unmarshalTypeError):
+			if unmarshalTypeError.Field != "" {
+				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+			}
+			return fmt.Errorf("body contains incorrect JSON type (at character %d)", unmarshalTypeError.Offset)
+		case errors.Is(err, io.EOF):
+			return errors.New("body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+			return fmt.Errorf("body contains unknown key %s", fieldName)
+		case errors.As(err, &maxBytesError):
+			return fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit)
+		default:
+			return err
+		}
+	}
+
+	// Make sure the body only contained a single JSON value.
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// errorJSON sends an error envelope with the given status. If the envelope
+// can't be written we fall back to a plain 500.
+func (app *application) errorJSON(w http.ResponseWriter, r *http.Request,
+	status int, message string, fields map[string]string) {
+	body := envelope{"error": apiError{Status: status, Message: message, Fields: fields}}
+
+	err := app.writeJSON(w, status, body, nil)
+	if err != nil {
+		app.logServerError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// serverErrorJSON is the JSON API version of serverError.
+func (app *application) serverErrorJSON(w http.ResponseWriter,
+	r *http.Request, err error) {
+	app.logServerError(r, err)
+
+	app.errorJSON(w, r, http.StatusInternalServerError,
+		http.StatusText(http.StatusInternalServerError), nil)
+}
+
+// clientErrorJSON is the JSON API version of clientError.
+func (app *application) clientErrorJSON(w http.ResponseWriter,
+	r *http.Request, status int) {
+	app.errorJSON(w, r, status, http.StatusText(status), nil)
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"log/slog"
+	"net/http"
+)
+
+// maxRequestIDLength is the longest X-Request-ID we'll take from a client
+// or proxy instead of generating our own.
+const maxRequestIDLength = 64
+
+// validRequestID reports whether id is safe to reuse: not too long and
+// made of letters, digits and a little punctuation, so that nobody can
+// write arbitrary text into our logs or response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// requestID gives every request an ID, which it stores in the request
+// context and sends back in the X-Request-ID response header. An ID set by
+// a proxy in front of us (or the client) is kept, so that its logs and
+// ours can be matched up. It must come before anything which logs.
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+
+		ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// responseRecorder wraps a ResponseWriter to remember the status code and
+// the number of bytes written, for the access log.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+// WriteHeader records the status code. Informational 1xx responses are
+// passed on but not recorded, as the final status comes after them.
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 && status >= 200 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written. Writing without calling WriteHeader()
+// first sends a 200 OK.
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	n, err := rec.ResponseWriter.Write(b)
+	rec.size += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController get at the underlying writer, for
+// flushing and the like.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// contextHandler is a slog.Handler which adds the request ID from the
+// context to every record logged with one of the ...Context() methods, so
+// that all the log lines for a request can be found together.
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request ID and passes the record on.
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id, ok := ctx.Value(requestIDContextKey).(string); ok {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs returns a contextHandler wrapping the new handler, so that
+// loggers made with logger.With() keep adding the request ID.
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup is like WithAttrs.
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
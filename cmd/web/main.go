@@ -4,22 +4,54 @@ import (
 	"database/sql"
 	"flag"
 	"html/template"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 
 	// import our costum models package
 	"github.com/fatonh/lovrinbox/internal/models"
+	"github.com/fatonh/lovrinbox/internal/ratelimit"
+	"github.com/fatonh/lovrinbox/ui"
 
+	"github.com/alexedwards/scs/v2"
 	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
 )
 
 // add logger and snippets fields to the application struct
 // so we can use it in our handler methods
 type application struct {
 	logger        *slog.Logger
-	snippets      *models.SnippetModel
+	snippets      models.SnippetStore
+	users         models.UserStore
 	templateCache map[string]*template.Template
+	// uiFS holds the templates and static files. devMode re-parses the
+	// templates from it on every request.
+	uiFS           fs.FS
+	devMode        bool
+	sessionManager *scs.SessionManager
+	// rendered caches the HTML of Markdown snippets.
+	rendered *renderCache
+	// unlockGuesses limits wrong passwords for password-protected
+	// snippets.
+	unlockGuesses *guessLimiter
+	// rateLimits are the request limits, and limiter holds the token
+	// buckets, in memory or in the database.
+	rateLimits rateLimitConfig
+	limiter    ratelimit.Store
+	// trustedProxies are the reverse proxies whose proxyHeader we believe
+	// when working out the client's IP.
+	trustedProxies trustedProxies
+	proxyHeader    proxyHeader
+	// wg tracks goroutines started with app.background(), so that we can
+	// wait for them during a graceful shutdown.
+	wg sync.WaitGroup
+	// stop is closed when the server starts shutting down, to tell
+	// long-running background workers to return.
+	stop chan struct{}
 }
 
 func main() {
@@ -28,24 +60,126 @@ func main() {
 	// the default value is ":4000"
 	addr := flag.String("addr", ":4000", "HTTP network address")
 
-	// define a new command-line flag for the MYSQL DSN string
-	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true",
-		"MySQL data source name")
+	// define a command-line flag to pick the storage backend. "memory"
+	// doesn't need a database server at all, which is handy for development.
+	dbDriver := flag.String("db-driver", "mysql",
+		"Database driver (mysql, sqlite or memory)")
+
+	// define a new command-line flag for the DSN string. If it's left
+	// empty we fall back to a sensible default for the chosen driver.
+	dsn := flag.String("dsn", "", "Data source name (defaults depend on -db-driver)")
+
+	// define a flag to apply any pending schema migrations at startup.
+	autoMigrate := flag.Bool("auto-migrate", false,
+		"Apply pending database migrations at startup")
+
+	// define flags for the session timeouts. The lifetime is an absolute
+	// limit from when the session was created, while the idle timeout
+	// expires sessions which haven't been used for a while.
+	sessionLifetime := flag.Duration("session-lifetime", 12*time.Hour,
+		"Absolute session lifetime")
+	sessionIdleTimeout := flag.Duration("session-idle-timeout", time.Hour,
+		"Session idle timeout (0 disables it)")
+
+	// define a flag to mark the session cookie as Secure, so browsers only
+	// send it over HTTPS.
+	secureCookies := flag.Bool("secure-cookies", false,
+		"Only send the session cookie over HTTPS")
+
+	// define flags for the http.Server timeouts, and how long to wait for
+	// in-flight requests to finish when shutting down.
+	var srvCfg serverConfig
+	flag.DurationVar(&srvCfg.readTimeout, "read-timeout", 5*time.Second,
+		"Maximum duration for reading an entire request")
+	flag.DurationVar(&srvCfg.readHeaderTimeout, "read-header-timeout", 2*time.Second,
+		"Maximum duration for reading request headers")
+	flag.DurationVar(&srvCfg.writeTimeout, "write-timeout", 10*time.Second,
+		"Maximum duration before timing out writes of the response")
+	flag.DurationVar(&srvCfg.idleTimeout, "idle-timeout", time.Minute,
+		"Maximum time to wait for the next request on keep-alive connections")
+	flag.DurationVar(&srvCfg.shutdownTimeout, "shutdown-timeout", 30*time.Second,
+		"Grace period for in-flight requests during shutdown")
+
+	// define flags for serving HTTPS. If -redirect-addr is set as well, a
+	// second listener redirects plain HTTP requests to HTTPS.
+	flag.StringVar(&srvCfg.tlsCert, "tls-cert", "", "TLS certificate file (enables HTTPS)")
+	flag.StringVar(&srvCfg.tlsKey, "tls-key", "", "TLS private key file")
+	flag.StringVar(&srvCfg.redirectAddr, "redirect-addr", "",
+		"Optional HTTP address which redirects to HTTPS (e.g. \":80\")")
+
+	// define a flag to read the templates and static files from ./ui on
+	// disk instead of the copies embedded in the binary, re-parsing the
+	// templates on every request.
+	dev := flag.Bool("dev", false,
+		"Serve templates and static files from ./ui and reload them on every request")
+
+	// define flags for the background worker which removes expired
+	// snippets from the database.
+	reapInterval := flag.Duration("reap-interval", time.Hour,
+		"How often to remove expired snippets (0 disables it)")
+	reapBatchSize := flag.Int("reap-batch-size", 500,
+		"Maximum number of expired snippets removed per transaction")
+	reapArchive := flag.Bool("reap-archive", false,
+		"Move expired snippets to the snippets_archive table instead of deleting them")
+
+	// define flags for the request rate limits, written as requests/period
+	// (or "off"), and where the token buckets are kept. "db" shares them
+	// between instances through the database.
+	rateLimits := rateLimitConfig{
+		read:  ratelimit.Every(120, time.Minute),
+		write: ratelimit.Every(20, time.Minute),
+		api:   ratelimit.Every(60, time.Minute),
+	}
+	flag.Var(&rateLimits.read, "rate-limit-read", "Rate limit for page views per client (e.g. 120/1m, or off)")
+	flag.Var(&rateLimits.write, "rate-limit-write", "Rate limit for form submissions and pastes per client")
+	flag.Var(&rateLimits.api, "rate-limit-api", "Rate limit for JSON API requests per IP address")
+	rateLimitStore := flag.String("rate-limit-store", "memory",
+		"Where to keep rate limit buckets (memory, or db to share them between instances)")
+
+	// define a flag for the reverse proxies in front of us, like nginx, so
+	// that we log and limit the real client rather than the proxy.
+	var proxies trustedProxies
+	flag.Var(&proxies, "trusted-proxies",
+		"Comma-separated CIDRs of reverse proxies whose -proxy-header is trusted")
+	proxyHdr := headerXForwardedFor
+	flag.Var(&proxyHdr, "proxy-header",
+		"Forwarding header the trusted proxies set (x-forwarded-for, with X-Forwarded-Proto, or forwarded)")
 
 	flag.Parse()
 
+	srvCfg.addr = *addr
+
+	if *dsn == "" {
+		*dsn = defaultDSN(*dbDriver)
+	}
+
 	// use the slog.NEW() function to create a new logger
 	// which writes messages to the standard output stream
 	// which write to the standard out stream and uses the
 	// the default settings.
-	logger := slog.New(slog.NewJSONHandler(os.Stdout,
-		&slog.HandlerOptions{Level: slog.LevelDebug, AddSource: true}))
-
-	//to keep the main() function tidy i've put the code for crateing
-	// a connection pool into the separet openDB function below.
-	// We pass openDB the DSN string as a parameter form
-	// command-line flag
-	db, err := openDB(*dsn)
+	logger := slog.New(contextHandler{slog.NewJSONHandler(os.Stdout,
+		&slog.HandlerOptions{Level: slog.LevelDebug, AddSource: true})})
+
+	if (srvCfg.tlsCert == "") != (srvCfg.tlsKey == "") {
+		logger.Error("-tls-cert and -tls-key must be used together")
+		os.Exit(1)
+	}
+
+	// If the first non-flag argument is "migrate", run the migration command
+	// (e.g. `web -db-driver=sqlite migrate up`) instead of the server.
+	if flag.Arg(0) == "migrate" {
+		err := runMigrate(*dbDriver, *dsn, flag.Args()[1:])
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// openStores opens the database (if the driver needs one) and returns
+	// the matching snippet, user and session stores. db is nil for the
+	// in-memory backend.
+	stores, err := openStores(*dbDriver, *dsn)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
@@ -54,50 +188,147 @@ func main() {
 
 	// we also defer a call to db.Close(), so that the
 	// connection pool is closed before the main() function exits
-	defer db.Close()
+	db := stores.db
+	if db != nil {
+		defer db.Close()
+	}
+
+	// Bring the schema up to date before we start serving requests, if
+	// we've been asked to.
+	if *autoMigrate && db != nil {
+		applied, err := migrateUp(db, *dbDriver)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+
+		for _, m := range applied {
+			logger.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+	}
+
+	// Keep the rate limit buckets in this process, unless we've been asked
+	// to share them through the database.
+	var limiter ratelimit.Store
+	switch *rateLimitStore {
+	case "memory":
+		limiter = ratelimit.NewMemoryStore()
+	case "db":
+		if stores.rateLimits == nil {
+			logger.Error("-rate-limit-store=db needs the mysql or sqlite driver")
+			os.Exit(1)
+		}
+		limiter = stores.rateLimits
+	default:
+		logger.Error("unsupported rate limit store", "rate-limit-store", *rateLimitStore)
+		os.Exit(1)
+	}
+
+	// Use the embedded files, unless we're in -dev mode.
+	var uiFS fs.FS = ui.Files
+	if *dev {
+		uiFS = os.DirFS("./ui")
+	}
 
 	// Initialize a new template cache...
-	templateCache, err := newTemplateCache()
+	templateCache, err := newTemplateCache(uiFS)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
 	}
 
+	// Use the scs.New() function to initialize a new session manager. Then
+	// we configure it to use our database as the session store (or an
+	// in-memory store for the memory driver) and set the timeouts and
+	// cookie settings from the command-line flags.
+	sessionManager := scs.New()
+	sessionManager.Store = stores.sessions
+	sessionManager.Lifetime = *sessionLifetime
+	sessionManager.IdleTimeout = *sessionIdleTimeout
+	sessionManager.Cookie.HttpOnly = true
+	sessionManager.Cookie.SameSite = http.SameSiteLaxMode
+	// The cookie is always marked Secure when we're serving HTTPS ourselves.
+	sessionManager.Cookie.Secure = *secureCookies || srvCfg.tlsEnabled()
+
 	// Initialize a new instance of application containing
 	// the dependencies for our application struct.
 	app := &application{
-		logger:        logger,
-		snippets:      &models.SnippetModel{DB: db},
-		templateCache: templateCache,
-	}
-
-	// use the Info() method to log the starting server message
-	// at info severity level
-	logger.Info("Starting server", "addr", *addr)
-
-	// use the htt.ListenAndServe function to start
-	// a new web server. We pass in two parameters: The TCP netweokd address
-	// to listen on (in this case ":4000") and the servemux
-	// we just created. If http.ListenAndServe returns an error, we log it and exit
-	// we use log.Fatal function
-	// to log the error message and terminate the program.
-	// Note that any error returned by http.ListenAndServe is
-	// allways non-nil.
-	err = http.ListenAndServe(*addr, app.routes())
-
-	// use Error() method to log the error message returned by
-	// http.ListenAndServe at error severity level
-	// and then call os.Exit(1) to terminate the program
-	logger.Error(err.Error())
-	os.Exit(1)
+		logger:         logger,
+		snippets:       stores.snippets,
+		users:          stores.users,
+		templateCache:  templateCache,
+		uiFS:           uiFS,
+		devMode:        *dev,
+		sessionManager: sessionManager,
+		rendered:       newRenderCache(renderCacheSize),
+		unlockGuesses:  newGuessLimiter(maxUnlockGuesses, unlockWindow),
+		rateLimits:     rateLimits,
+		limiter:        limiter,
+		trustedProxies: proxies,
+		proxyHeader:    proxyHdr,
+		stop:           make(chan struct{}),
+	}
+
+	// Start the expired snippet reaper in the background. It's tracked by
+	// app.wg, so the server waits for it to stop before we close the
+	// database.
+	if *reapInterval > 0 {
+		r := &reaper{
+			snippets:  app.snippets,
+			logger:    logger,
+			interval:  *reapInterval,
+			batchSize: max(*reapBatchSize, 1),
+			archive:   *reapArchive,
+			now:       time.Now,
+		}
 
+		app.background(func() {
+			r.run(app.stop)
+		})
+	}
+
+	// Forget rate limit buckets which have filled up again, so the store
+	// doesn't grow forever.
+	if rateLimits.enabled() {
+		app.background(func() {
+			app.evictRateLimits(app.stop)
+		})
+	}
+
+	logger.Info("using database", "db-driver", *dbDriver)
+
+	// serve() blocks until the server has been shut down by a SIGINT or
+	// SIGTERM signal and any background tasks have finished.
+	err = app.serve(srvCfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Stop the session store's cleanup goroutine before the deferred
+	// db.Close() runs, so it can't use a closed connection pool.
+	if s, ok := stores.sessions.(interface{ StopCleanup() }); ok {
+		s.StopCleanup()
+	}
+}
+
+// defaultDSN returns the DSN used when the -dsn flag is left empty.
+func defaultDSN(driver string) string {
+	switch driver {
+	case "mysql":
+		return "web:pass@/snippetbox?parseTime=true"
+	case "sqlite":
+		return "file:snippetbox.db?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	default:
+		return ""
+	}
 }
 
 // the openDB() function wraps sql.Open() and
-// returns a sql.DB connection pool for a given DSN string
-func openDB(dsn string) (*sql.DB, error) {
+// returns a sql.DB connection pool for a given driver and DSN string
+func openDB(driver, dsn string) (*sql.DB, error) {
 	// use sql.Open to create an empty connection pool
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
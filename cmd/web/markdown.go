@@ -0,0 +1,100 @@
+package main
+
+import (
+	"container/list"
+	"html/template"
+	"sync"
+
+	"github.com/fatonh/lovrinbox/internal/markdown"
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// renderCacheSize is the number of rendered Markdown snippets kept in
+// memory.
+const renderCacheSize = 1000
+
+// renderKey identifies one version of a snippet. Editing a snippet bumps
+// its revision, so a cached rendering is never served for the wrong
+// content. Old versions simply age out of the cache.
+type renderKey struct {
+	id       int
+	revision int
+}
+
+type renderEntry struct {
+	key  renderKey
+	html template.HTML
+}
+
+// renderCache is a least-recently-used cache of rendered Markdown. It's safe
+// for concurrent use.
+type renderCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[renderKey]*list.Element
+	order   *list.List
+}
+
+func newRenderCache(size int) *renderCache {
+	return &renderCache{
+		size:    size,
+		entries: make(map[renderKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *renderCache) get(key renderKey) (template.HTML, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+
+	c.order.MoveToFront(e)
+
+	return e.Value.(renderEntry).html, true
+}
+
+func (c *renderCache) put(key renderKey, html template.HTML) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(renderEntry{key: key, html: html})
+
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(renderEntry).key)
+	}
+}
+
+// renderMarkdown returns the sanitized HTML for a Markdown snippet, from the
+// cache if this version has been rendered before. Burn-after-reading
+// snippets are only ever shown once, so they're never cached.
+func (app *application) renderMarkdown(s models.Snippet) (template.HTML, error) {
+	if s.BurnAfterReading {
+		return markdown.Render(s.Content)
+	}
+
+	key := renderKey{id: s.ID, revision: s.Revision}
+
+	if html, ok := app.rendered.get(key); ok {
+		return html, nil
+	}
+
+	html, err := markdown.Render(s.Content)
+	if err != nil {
+		return "", err
+	}
+
+	app.rendered.put(key, html)
+
+	return html, nil
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"time"
+
+	"github.com/fatonh/lovrinbox/internal/models"
 )
 
 // middleware pattern.
@@ -23,27 +29,46 @@ func commonHeaders(next http.Handler) http.Handler {
 
 		w.Header().Set("Server", "GO")
 
+		// Only send HSTS over HTTPS. Browsers ignore it on plain HTTP
+		// responses anyway, and this way it's on whenever TLS is.
+		if r.TLS != nil {
+			w.Header().Set("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		}
+
 		next.ServeHTTP(w, r)
 	})
 
 }
 
-// logRequest is a middleware which logs basic information about each
-// HTTP request received.
-// logRequest -|> commonHeaders -|> servemux -|> application handler
+// logRequest is a middleware which logs one line for each HTTP request once
+// it has been handled, with the response status, size and how long it took.
+// It comes after requestID and realIP, and before recoverPanic, so that the
+// 500 sent after a panic is logged too.
+// requestID -|> realIP -|> logRequest -|> recoverPanic -|> commonHeaders -|> servemux
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var (
-			ip     = r.RemoteAddr
+			start  = time.Now()
+			rec    = &responseRecorder{ResponseWriter: w}
+			ip     = clientIP(r)
 			proto  = r.Proto
 			method = r.Method
 			uri    = r.URL.RequestURI()
 		)
 
-		app.logger.Info("received request", "ip", ip, "proto", proto,
-			"method", method, "uri", uri)
+		next.ServeHTTP(rec, r)
 
-		next.ServeHTTP(w, r)
+		// A handler which writes nothing at all still sends a 200.
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		latency := time.Since(start)
+
+		app.logger.InfoContext(r.Context(), "completed request", "ip", ip, "proto", proto,
+			"method", method, "uri", uri, "status", status, "size", rec.size,
+			"latency_ms", float64(latency.Microseconds())/1000)
 	})
 }
 
@@ -72,3 +97,148 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// withAuthenticatedUser returns a copy of r whose context records that the
+// request comes from the user with the given ID.
+func withAuthenticatedUser(r *http.Request, id int) *http.Request {
+	ctx := context.WithValue(r.Context(), isAuthenticatedContextKey, true)
+	ctx = context.WithValue(ctx, authenticatedUserIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// requireAuthentication redirects unauthenticated users to the login page.
+// The path they were trying to reach is remembered in the session so that
+// we can send them back there once they've logged in.
+func (app *application) requireAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// If the user is not authenticated, redirect them to the login page and
+		// return from the middleware chain so that no subsequent handlers in
+		// the chain are executed.
+		if !app.isAuthenticated(r) {
+			if r.Method == http.MethodGet {
+				app.sessionManager.Put(r.Context(), "redirectPathAfterLogin", r.URL.RequestURI())
+			}
+			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+			return
+		}
+
+		// Otherwise set the "Cache-Control: no-store" header so that pages
+		// which require authentication are not stored in the users browser cache (or
+		// other intermediary cache).
+		w.Header().Add("Cache-Control", "no-store")
+
+		// And call the next handler in the chain.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks the session for an authenticated user ID and, if the
+// user still exists in the database, marks the request as authenticated in
+// its context.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Retrieve the authenticatedUserID value from the session using the
+		// GetInt() method. This will return the zero value for an int (0) if no
+		// "authenticatedUserID" value is in the session -- in which case we
+		// call the next handler in the chain as normal and return.
+		id := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+		if id == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Otherwise, we check to see if a user with that ID exists in our
+		// database.
+		exists, err := app.users.Exists(id)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		// If a matching user is found, we know that the request is
+		// coming from an authenticated user who exists in our database. We
+		// create a new copy of the request (with the user marked as
+		// authenticated in the request context) and assign it to r.
+		if exists {
+			r = withAuthenticatedUser(r, id)
+		}
+
+		// Call the next handler in the chain.
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrf protects state-changing requests against cross-site request forgery.
+// Requests which the browser tells us are cross-origin are rejected
+// straight away, and everything else must carry the session's CSRF token.
+// It must run after the session middleware, because the token is stored in
+// the session.
+func (app *application) csrf(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := app.csrfToken(r)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		// The response depends on the session cookie, so make sure caches
+		// don't share it between users.
+		w.Header().Add("Vary", "Cookie")
+
+		if !safeMethods[r.Method] {
+			if !sameOrigin(r) || !app.validCSRFToken(r, token) {
+				app.logger.WarnContext(r.Context(), "rejected request with failed CSRF check",
+					"ip", clientIP(r), "method", r.Method, "uri", r.URL.RequestURI())
+				app.clientError(w, r, http.StatusForbidden)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireJSON rejects request bodies which aren't sent as application/json.
+// Besides being good API hygiene, this means a cross-site HTML form can't
+// submit to the API, because browsers won't send a JSON content type
+// without a CORS preflight.
+func (app *application) requireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/json" {
+			app.clientErrorJSON(w, r, http.StatusUnsupportedMediaType)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIAuthentication checks HTTP Basic credentials (the user's email
+// and password) on API requests which change data. The API doesn't use the
+// session cookie, so it needs its own way to identify the caller. The user
+// is recorded in the request context just like the authenticate middleware
+// does.
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email, password, ok := r.BasicAuth()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="lovrinbox", charset="UTF-8"`)
+			app.clientErrorJSON(w, r, http.StatusUnauthorized)
+			return
+		}
+
+		id, err := app.users.Authenticate(email, password)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="lovrinbox", charset="UTF-8"`)
+				app.clientErrorJSON(w, r, http.StatusUnauthorized)
+			} else {
+				app.serverErrorJSON(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, withAuthenticatedUser(r, id))
+	})
+}
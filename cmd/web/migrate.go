@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/fatonh/lovrinbox/internal/migrations"
+)
+
+const migrateUsage = "usage: migrate up|down|status|to N"
+
+// runMigrate implements the `migrate` command. It shares openDB() with the
+// web server so that it connects to the database in exactly the same way.
+func runMigrate(driver, dsn string, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if driver == "memory" {
+		return errors.New("the memory driver has no schema to migrate")
+	}
+
+	db, err := openDB(driver, dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := migrations.New(db, driver)
+	if err != nil {
+		return err
+	}
+
+	var done []migrations.Migration
+
+	switch args[0] {
+	case "up":
+		done, err = m.Up()
+	case "down":
+		done, err = m.Down()
+	case "to":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+
+		done, err = m.To(version)
+	case "status":
+		return printMigrationStatus(m)
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	// Report whatever did run, even if a later migration failed.
+	for _, mig := range done {
+		fmt.Printf("%04d_%s\n", mig.Version, mig.Name)
+	}
+
+	if errors.Is(err, migrations.ErrNoChange) || (err == nil && len(done) == 0) {
+		fmt.Println("no change")
+		return nil
+	}
+
+	return err
+}
+
+// printMigrationStatus writes a table of every known migration and whether
+// it has been applied.
+func printMigrationStatus(m *migrations.Migrator) error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+
+	for _, s := range statuses {
+		applied := "pending"
+		if s.Applied {
+			applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+
+	return tw.Flush()
+}
+
+// migrateUp applies every pending migration. It's used by the -auto-migrate
+// flag when the server starts.
+func migrateUp(db *sql.DB, driver string) ([]migrations.Migration, error) {
+	m, err := migrations.New(db, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Up()
+}
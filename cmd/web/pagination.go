@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+	"github.com/fatonh/lovrinbox/internal/validator"
+)
+
+// The number of snippets on a page of a listing, unless the request asks
+// for a different number with ?limit=.
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageMetadata describes a page of a listing, so that templates and API
+// clients can link to the pages on either side of it. Next and Prev are
+// the cursors to pass as ?after= and ?before= respectively. Path is the
+// listing's URL path, for the templates' links.
+type pageMetadata struct {
+	Sort  string `json:"sort"`
+	Limit int    `json:"limit"`
+	Tag   string `json:"tag,omitempty"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Path  string `json:"-"`
+}
+
+// listQuery holds the pagination parameters from a listing's query string,
+// along with any validation errors.
+type listQuery struct {
+	models.ListOptions
+	validator.Validator
+}
+
+// readListQuery reads the sort, limit, tag, after and before parameters
+// shared by the listing pages and the JSON API.
+func readListQuery(r *http.Request) listQuery {
+	qs := r.URL.Query()
+
+	q := listQuery{
+		ListOptions: models.ListOptions{
+			Sort:   qs.Get("sort"),
+			After:  qs.Get("after"),
+			Before: qs.Get("before"),
+			Limit:  queryInt(r, "limit", defaultPageLimit),
+			Tag:    qs.Get("tag"),
+		},
+	}
+
+	if q.Sort == "" {
+		q.Sort = models.SortCreated
+	}
+
+	q.CheckField(validator.PermittedValue(q.Sort, models.SortOrders...), "sort", "This field must equal created, expires or title")
+	q.CheckField(q.Limit >= 1 && q.Limit <= maxPageLimit, "limit", "This field must be between 1 and 100")
+	q.CheckField(q.After == "" || q.Before == "", "before", "This field can't be used together with after")
+	q.CheckField(q.Tag == "" || validTag(q.Tag), "tag", "This field must be a valid tag")
+
+	return q
+}
+
+// newPageMetadata returns the metadata for a page fetched with q.
+func newPageMetadata(q listQuery, page models.Page) pageMetadata {
+	path := "/snippets"
+	if q.Tag != "" {
+		path = "/tag/" + q.Tag
+	}
+
+	return pageMetadata{
+		Sort:  q.Sort,
+		Limit: q.Limit,
+		Tag:   q.Tag,
+		Next:  page.Next,
+		Prev:  page.Prev,
+		Path:  path,
+	}
+}
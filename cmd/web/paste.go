@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// maxPasteBytes caps the size of a paste, including any multipart overhead.
+const maxPasteBytes = 1_048_576
+
+// defaultPasteExpires is the expiry, in days, of pastes which don't ask for
+// a particular one.
+const defaultPasteExpires = 7
+
+// pasteParam reads a paste option from the query string, or failing that
+// from the matching X- header, e.g. ?title= or X-Title.
+func pasteParam(r *http.Request, name string) string {
+	if value := r.URL.Query().Get(name); value != "" {
+		return value
+	}
+	return r.Header.Get("X-" + name)
+}
+
+// pasteTitle makes up a title for pastes which aren't given one, from the
+// first non-blank line of the content.
+func pasteTitle(content string) string {
+	for line := range strings.Lines(content) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if utf8.RuneCountInString(line) > 50 {
+			line = string([]rune(line)[:50]) + "…"
+		}
+		return line
+	}
+
+	return "Untitled paste"
+}
+
+// readPaste returns the content of a paste request and, for uploads, the
+// file name. The body is either the content itself, as sent by
+// `curl --data-binary @-`, or a multipart form with a "file" field, as sent
+// by `curl -F file=@notes.txt`.
+func readPaste(w http.ResponseWriter, r *http.Request) (content string, filename string, err error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasteBytes)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var body []byte
+
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			// Pass on a body which is too large, so that it gets a 413
+			// like a plain one does.
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				return "", "", err
+			}
+			return "", "", errors.New(`multipart uploads must have a "file" field`)
+		}
+		defer file.Close()
+
+		body, err = io.ReadAll(file)
+		if err != nil {
+			return "", "", err
+		}
+
+		filename = header.Filename
+	} else {
+		// Anything else, including the application/x-www-form-urlencoded
+		// which curl sends by default, is taken as the content as it is.
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if !utf8.Valid(body) {
+		return "", "", errors.New("content must be UTF-8 text")
+	}
+
+	return string(body), filename, nil
+}
+
+// snippetPaste creates a snippet from a plain request body, pastebin style:
+//
+//	echo foo | curl --data-binary @- https://box/
+//	curl -F file=@notes.txt 'https://box/?title=Notes&expires=1'
+//
+// The title, expiry (in days) and visibility come from the title, expires
+// and visibility query parameters or the X-Title, X-Expires and
+// X-Visibility headers. Password-protected pastes take the password from
+// the X-Password header only, so that it doesn't end up in access logs.
+// Pastes are anonymous unless the request has HTTP Basic credentials. The
+// reply is the URL of the new snippet, as plain text.
+func (app *application) snippetPaste(w http.ResponseWriter, r *http.Request) {
+	// Browsers send Sec-Fetch-Site or Origin headers, which lets us turn
+	// away cross-site form posts. Command-line clients don't send them.
+	if !sameOrigin(r) {
+		app.clientError(w, r, http.StatusForbidden)
+		return
+	}
+
+	userID := 0
+	if email, password, ok := r.BasicAuth(); ok {
+		id, err := app.users.Authenticate(email, password)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="lovrinbox", charset="UTF-8"`)
+				app.clientError(w, r, http.StatusUnauthorized)
+			} else {
+				app.serverError(w, r, err)
+			}
+			return
+		}
+		userID = id
+	}
+
+	content, filename, err := readPaste(w, r)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, fmt.Sprintf("paste must not be larger than %d bytes", maxBytesError.Limit),
+				http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	title := pasteParam(r, "title")
+	if title == "" {
+		title = filename
+	}
+	if title == "" {
+		title = pasteTitle(content)
+	}
+
+	expires := defaultPasteExpires
+	if value := pasteParam(r, "expires"); value != "" {
+		expires, err = strconv.Atoi(value)
+		if err != nil {
+			expires = -1
+		}
+	}
+
+	visibility := pasteParam(r, "visibility")
+	if visibility == "" {
+		visibility = models.VisibilityPublic
+	}
+
+	form := snippetCreateForm{
+		Title:      title,
+		Content:    content,
+		Expires:    expires,
+		Format:     models.FormatCode,
+		Visibility: visibility,
+		Password:   r.Header.Get("X-Password"),
+	}
+
+	form.validate()
+
+	// Only the owner can see a private snippet, so an anonymous one would
+	// be no use to anybody.
+	form.CheckField(userID != 0 || form.Visibility != models.VisibilityPrivate,
+		"visibility", "Private pastes need HTTP Basic credentials")
+
+	if !form.Valid() {
+		var msg strings.Builder
+		for _, field := range slices.Sorted(maps.Keys(form.FieldErrors)) {
+			fmt.Fprintf(&msg, "%s: %s\n", field, form.FieldErrors[field])
+		}
+		http.Error(w, strings.TrimSuffix(msg.String(), "\n"), http.StatusUnprocessableEntity)
+		return
+	}
+
+	slug, err := app.snippets.Insert(form.newSnippet(userID))
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	scheme := app.trustedProxies.scheme(r, app.proxyHeader)
+	url := fmt.Sprintf("%s://%s/snippet/view/%s", scheme, r.Host, slug)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Location", url)
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, url)
+}
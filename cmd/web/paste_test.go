@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSnippetPasteURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		proxies string
+		proto   string
+		want    string
+	}{
+		{"Direct", "", "", "http://"},
+		{"Untrusted X-Forwarded-Proto", "", "https", "http://"},
+		{"Trusted proxy over HTTPS", "127.0.0.1", "https", "https://"},
+		{"Trusted proxy over HTTP", "127.0.0.1", "http", "http://"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			err := app.trustedProxies.Set(tt.proxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts := newTestServer(t, app.routes())
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/", strings.NewReader("echo hello"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+
+			rs, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			if rs.StatusCode != http.StatusCreated {
+				t.Fatalf("got status %d; want %d", rs.StatusCode, http.StatusCreated)
+			}
+
+			location := rs.Header.Get("Location")
+			wantPrefix := tt.want + strings.TrimPrefix(ts.URL, "http://") + "/snippet/view/"
+			if !strings.HasPrefix(location, wantPrefix) {
+				t.Errorf("got location %q; want it to start with %q", location, wantPrefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fatonh/lovrinbox/internal/ratelimit"
+)
+
+// rateLimitEvictInterval is how often buckets which have filled up again
+// are forgotten.
+const rateLimitEvictInterval = time.Minute
+
+// rateLimitConfig holds the limits set by the -rate-limit-* flags. Reads
+// are GET and HEAD requests for pages, writes are anything else, like
+// creating a snippet or logging in, and the API has its own limit.
+type rateLimitConfig struct {
+	read  ratelimit.Limit
+	write ratelimit.Limit
+	api   ratelimit.Limit
+}
+
+// enabled reports whether any of the limits is switched on.
+func (cfg rateLimitConfig) enabled() bool {
+	return cfg.read.Enabled() || cfg.write.Enabled() || cfg.api.Enabled()
+}
+
+// rateLimitKey says who a request counts against: the authenticated user
+// if there is one, so that people sharing an IP address don't use up each
+// other's requests, and otherwise the client's IP address.
+func (app *application) rateLimitKey(r *http.Request) string {
+	if id := app.authenticatedUserID(r); id != 0 {
+		return "user:" + strconv.Itoa(id)
+	}
+	return "ip:" + clientIP(r)
+}
+
+// takeToken takes a token for the request from the bucket for the given
+// class of requests. If the store fails we let the request through, as
+// being down because of the rate limiter would be worse than not limiting
+// for a while.
+func (app *application) takeToken(r *http.Request, class string, limit ratelimit.Limit) (bool, time.Duration) {
+	if !limit.Enabled() {
+		return true, 0
+	}
+
+	key := class + ":" + app.rateLimitKey(r)
+
+	allowed, retryAfter, err := app.limiter.Take(key, limit, time.Now())
+	if err != nil {
+		app.logServerError(r, err)
+		return true, 0
+	}
+
+	if !allowed {
+		app.logger.WarnContext(r.Context(), "rate limit exceeded", "class", class, "key", key,
+			"method", r.Method, "uri", r.URL.RequestURI())
+	}
+
+	return allowed, retryAfter
+}
+
+// setRetryAfter tells the client how many whole seconds to wait before
+// trying again, rounding up so that it doesn't come back too early.
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+}
+
+// limitRequests applies the read limit to GET and HEAD requests and the
+// write limit to everything else, answering with 429 Too Many Requests once
+// the client has used up its bucket. In the dynamic chain it must come
+// after authenticate, so that logged in users are limited per user.
+func (app *application) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class, limit := "read", app.rateLimits.read
+		if !safeMethods[r.Method] {
+			class, limit = "write", app.rateLimits.write
+		}
+
+		allowed, retryAfter := app.takeToken(r, class, limit)
+		if !allowed {
+			setRetryAfter(w, retryAfter)
+			app.clientError(w, r, http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitAPI applies the API limit, with a JSON error. It runs before the
+// API's Basic authentication, so requests are limited per IP address, and
+// that limits password guessing through the API as well.
+func (app *application) limitAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := app.takeToken(r, "api", app.rateLimits.api)
+		if !allowed {
+			setRetryAfter(w, retryAfter)
+			app.clientErrorJSON(w, r, http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// evictRateLimits forgets full buckets every rateLimitEvictInterval until
+// stop is closed, so that the store doesn't keep a bucket for every client
+// it has ever seen.
+func (app *application) evictRateLimits(stop <-chan struct{}) {
+	ticker := time.NewTicker(rateLimitEvictInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			n, err := app.limiter.Evict(now)
+			if err != nil {
+				app.logger.Error("evicting rate limit buckets", "error", err.Error())
+				continue
+			}
+			if n > 0 {
+				app.logger.Debug("evicted rate limit buckets", "count", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/fatonh/lovrinbox/internal/highlight"
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// maxRawMaxAge caps how long clients and proxies may cache a raw snippet
+// without checking back. Snippets can be edited, so it's kept short; after
+// that the ETag makes revalidating cheap.
+const maxRawMaxAge = 5 * time.Minute
+
+// rawSnippet fetches the snippet in the URL for the raw and download
+// endpoints, sending an error response and returning false if it can't be
+// served.
+func (app *application) rawSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := app.resolveSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	// Burn-after-reading snippets can only be read once, through the
+	// confirmation page in the browser. They get a 404 like private
+	// snippets, so that these endpoints don't give away that a link is
+	// still unread.
+	if snippet.BurnAfterReading {
+		http.NotFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	// These endpoints don't use the session, so they can't tell who the
+	// owner is or whether a password has been entered. Private and
+	// password-protected snippets can only be read in the browser.
+	switch snippet.Visibility {
+	case models.VisibilityPrivate:
+		http.NotFound(w, r)
+		return models.Snippet{}, false
+	case models.VisibilityPassword:
+		app.clientError(w, r, http.StatusForbidden)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// serveSnippetContent writes the content of a snippet as plain text, with
+// caching headers. The ETag changes whenever the snippet is edited, and
+// http.ServeContent() answers conditional and range requests for us.
+func serveSnippetContent(w http.ResponseWriter, r *http.Request, s models.Snippet) {
+	maxAge := min(time.Until(s.Expires), maxRawMaxAge)
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	if s.Visibility == models.VisibilityUnlisted {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%d"`, s.Slug, s.Revision))
+
+	http.ServeContent(w, r, "", s.Updated, strings.NewReader(s.Content))
+}
+
+// snippetRaw serves the content of a snippet as plain text, e.g. for
+// `curl https://.../snippet/raw/1 | sh`.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.rawSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	serveSnippetContent(w, r, snippet)
+}
+
+// snippetDownload serves the content of a snippet as a file download, named
+// after its title and language.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.rawSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": snippetFilename(snippet),
+	})
+	w.Header().Set("Content-Disposition", disposition)
+
+	serveSnippetContent(w, r, snippet)
+}
+
+// snippetFilename turns a snippet's title into a file name like
+// "my-first-snippet.go". Anything other than letters and digits becomes a
+// dash. The extension comes from the language, except for Markdown and
+// plain text snippets.
+func snippetFilename(s models.Snippet) string {
+	var b strings.Builder
+
+	dash := false
+	for _, r := range strings.ToLower(s.Title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+
+		if b.Len() >= 50 {
+			break
+		}
+	}
+
+	name := b.String()
+	if name == "" {
+		name = "snippet-" + s.Slug
+	}
+
+	var ext string
+	switch s.Format {
+	case models.FormatMarkdown:
+		ext = "md"
+	case models.FormatPlain:
+		ext = "txt"
+	default:
+		ext = highlight.Ext(s.Language)
+	}
+
+	return name + "." + ext
+}
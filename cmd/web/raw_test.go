@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	insert := func(s models.NewSnippet) string {
+		t.Helper()
+
+		s.Title, s.Content, s.Expires, s.Format = "Raw", "echo hello", 7, models.FormatPlain
+		slug, err := app.snippets.Insert(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slug
+	}
+
+	public := insert(models.NewSnippet{Visibility: models.VisibilityPublic})
+	private := insert(models.NewSnippet{Visibility: models.VisibilityPrivate, UserID: 1})
+	burn := insert(models.NewSnippet{Visibility: models.VisibilityPublic, BurnAfterReading: true})
+	locked := insert(models.NewSnippet{Visibility: models.VisibilityPassword, Password: "hunter2hunter2"})
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Public", "/snippet/raw/" + public, http.StatusOK},
+		{"Public download", "/snippet/download/" + public, http.StatusOK},
+		{"Unknown", "/snippet/raw/AAAAAAAAAA", http.StatusNotFound},
+		{"Private", "/snippet/raw/" + private, http.StatusNotFound},
+		{"Burn after reading", "/snippet/raw/" + burn, http.StatusNotFound},
+		{"Burn after reading download", "/snippet/download/" + burn, http.StatusNotFound},
+		{"Password", "/snippet/raw/" + locked, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+
+			if code == http.StatusOK && body != "echo hello" {
+				t.Errorf("got body %q; want %q", body, "echo hello")
+			}
+		})
+	}
+
+	// The burn-after-reading snippet must still be there to be read in the
+	// browser.
+	if _, err := app.snippets.GetBySlug(burn); err != nil {
+		t.Errorf("burn after reading snippet: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// trustedProxies is the list of networks set by the -trusted-proxies flag.
+// Only proxies in these networks are believed about who they forwarded a
+// request for.
+type trustedProxies []netip.Prefix
+
+// String formats the list the way Set() parses it.
+func (p trustedProxies) String() string {
+	s := make([]string, len(p))
+	for i, prefix := range p {
+		s[i] = prefix.String()
+	}
+	return strings.Join(s, ",")
+}
+
+// Set parses a comma-separated list of CIDRs, like "10.0.0.0/8,::1/128".
+// A plain IP address is taken to be a network of one. IPv4-mapped IPv6
+// addresses are stored as plain IPv4, as that's how parseHop() returns
+// them. It makes *trustedProxies a flag.Value.
+func (p *trustedProxies) Set(s string) error {
+	var list trustedProxies
+
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return fmt.Errorf("invalid trusted proxy %q", field)
+			}
+			addr = addr.Unmap()
+			list = append(list, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q", field)
+		}
+
+		// ::ffff:10.0.0.0/104 is 10.0.0.0/8 written as IPv6, so it keeps
+		// the last 32 bits' worth of the prefix length.
+		if addr := prefix.Addr(); addr.Is4In6() {
+			bits := prefix.Bits() - 96
+			if bits < 0 {
+				return fmt.Errorf("invalid trusted proxy %q", field)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), bits)
+		}
+		list = append(list, prefix.Masked())
+	}
+
+	*p = list
+	return nil
+}
+
+// proxyHeader is the forwarding header set by the -proxy-header flag. Only
+// that one header is read from trusted proxies: the proxy overwrites or
+// appends to the header it writes, but passes any other one on from the
+// client untouched, so anyone could have written it.
+type proxyHeader string
+
+const (
+	headerXForwardedFor proxyHeader = "x-forwarded-for"
+	headerForwarded     proxyHeader = "forwarded"
+)
+
+// String returns the name of the header as given to the flag. The zero
+// value means X-Forwarded-For.
+func (h proxyHeader) String() string {
+	if h == "" {
+		return string(headerXForwardedFor)
+	}
+	return string(h)
+}
+
+// Set parses the name of the header, in any case. It makes *proxyHeader a
+// flag.Value.
+func (h *proxyHeader) Set(s string) error {
+	switch header := proxyHeader(strings.ToLower(strings.TrimSpace(s))); header {
+	case headerXForwardedFor, headerForwarded:
+		*h = header
+		return nil
+	default:
+		return fmt.Errorf("invalid proxy header %q (want x-forwarded-for or forwarded)", s)
+	}
+}
+
+// contains reports whether addr is one of the trusted proxies.
+func (p trustedProxies) contains(addr netip.Addr) bool {
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve works out the IP address of the client that made the request.
+// It starts from the address of the peer and, for as long as that's a
+// trusted proxy, steps back one hop through the given forwarding header.
+// The first address which isn't a trusted proxy is the client. Hops
+// further back were added by the client or by proxies we don't know, so
+// anyone could have written them and they're ignored.
+func (p trustedProxies) resolve(r *http.Request, header proxyHeader) string {
+	addr, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+
+	if !p.contains(addr) {
+		return addr.String()
+	}
+
+	hops := header.forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			// A trusted proxy forwarded something we can't read, like
+			// Forwarded's "unknown", so the last good hop is as far back
+			// as we can go.
+			break
+		}
+
+		addr = hop
+		if !p.contains(addr) {
+			break
+		}
+	}
+
+	return addr.String()
+}
+
+// scheme returns "https" if the client made the request over TLS, and
+// "http" if not. Behind a trusted proxy that's what the proxy says in its
+// header: X-Forwarded-Proto alongside X-Forwarded-For, or the proto=
+// parameter of Forwarded. The nearest proxy has the last word, so it must
+// pass on the scheme it was told by any proxy in front of it.
+func (p trustedProxies) scheme(r *http.Request, header proxyHeader) string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	addr, ok := parseHop(r.RemoteAddr)
+	if !ok || !p.contains(addr) {
+		return "http"
+	}
+
+	var protos []string
+	if header == headerForwarded {
+		protos = forwardedParams(r.Header, "proto")
+	} else {
+		protos = listValues(r.Header, "X-Forwarded-Proto")
+	}
+
+	if len(protos) > 0 && strings.EqualFold(protos[len(protos)-1], "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// forwardedFor returns the client addresses from the header, in the order
+// the proxies added them. Proxies append to the header, or add another
+// one, so the last address is the one added by the nearest proxy.
+func (header proxyHeader) forwardedFor(h http.Header) []string {
+	if header == headerForwarded {
+		return forwardedParams(h, "for")
+	}
+	return listValues(h, "X-Forwarded-For")
+}
+
+// forwardedParams returns the value of a parameter, like for= or proto=,
+// from each element of the Forwarded headers. Elements without it get an
+// empty string, so that they stop the walk in resolve() rather than being
+// skipped over.
+func forwardedParams(h http.Header, name string) []string {
+	var params []string
+
+	for _, value := range h.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			param := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, v, _ := strings.Cut(strings.TrimSpace(pair), "=")
+				if strings.EqualFold(key, name) {
+					param = strings.Trim(v, `"`)
+				}
+			}
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// listValues returns the comma-separated values of all the headers with
+// the given name, in order.
+func listValues(h http.Header, name string) []string {
+	var values []string
+
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+	}
+	return values
+}
+
+// parseHop parses an address as found in RemoteAddr or a forwarding header,
+// with or without a port and, for IPv6, square brackets.
+func parseHop(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// realIP resolves the client's IP address and stores it in the request
+// context, so that logging, rate limiting and everything else which asks
+// clientIP() agrees on who the client is. It must come before logRequest
+// and the rate limiters.
+func (app *application) realIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := app.trustedProxies.resolve(r, app.proxyHeader)
+
+		ctx := context.WithValue(r.Context(), clientIPContextKey, ip)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
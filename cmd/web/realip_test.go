@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"Empty", "", "", false},
+		{"CIDRs", "10.0.0.0/8, 192.168.1.0/24", "10.0.0.0/8,192.168.1.0/24", false},
+		{"Plain addresses", "127.0.0.1,::1", "127.0.0.1/32,::1/128", false},
+		{"Host bits masked", "10.1.2.3/8", "10.0.0.0/8", false},
+		{"IPv4-mapped address", "::ffff:10.0.0.1", "10.0.0.1/32", false},
+		{"IPv4-mapped CIDR", "::ffff:10.0.0.0/104", "10.0.0.0/8", false},
+		{"IPv6", "2001:db8::/32", "2001:db8::/32", false},
+		{"Not an address", "proxy.local", "", true},
+		{"Bad prefix length", "10.0.0.0/33", "", true},
+		{"Too wide for a mapped CIDR", "::ffff:0:0/90", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p trustedProxies
+			err := p.Set(tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v; want error %t", err, tt.wantErr)
+			}
+			if got := p.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyHeaderSet(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    proxyHeader
+		wantErr bool
+	}{
+		{"x-forwarded-for", headerXForwardedFor, false},
+		{"X-Forwarded-For", headerXForwardedFor, false},
+		{"forwarded", headerForwarded, false},
+		{"Forwarded", headerForwarded, false},
+		{"", "", true},
+		{"x-real-ip", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var h proxyHeader
+			err := h.Set(tt.value)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v; want error %t", err, tt.wantErr)
+			}
+			if h != tt.want {
+				t.Errorf("got %q; want %q", h, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesResolve(t *testing.T) {
+	var proxies trustedProxies
+	err := proxies.Set("10.0.0.0/8,::1,::ffff:192.168.0.0/112")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xff := map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}
+	fwd := map[string][]string{"Forwarded": {"for=192.0.2.60"}}
+
+	tests := []struct {
+		name       string
+		header     proxyHeader
+		remoteAddr string
+		headers    map[string][]string
+		want       string
+	}{
+		{"Direct client", headerXForwardedFor, "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"Untrusted peer's header ignored", headerXForwardedFor, "203.0.113.7:1234", xff, "203.0.113.7"},
+		{"Trusted peer without header", headerXForwardedFor, "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"One trusted hop", headerXForwardedFor, "10.0.0.1:1234", xff, "198.51.100.1"},
+		{"Zero value is X-Forwarded-For", "", "10.0.0.1:1234", xff, "198.51.100.1"},
+		{"Spoofed entries before the client", headerXForwardedFor, "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1, 10.0.0.2"}}, "198.51.100.1"},
+		{"Several headers", headerXForwardedFor, "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"1.2.3.4", "198.51.100.1"}}, "198.51.100.1"},
+		{"Only trusted hops", headerXForwardedFor, "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"Garbage hop stops the walk", headerXForwardedFor, "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"198.51.100.1, garbage"}}, "10.0.0.1"},
+		{"Forwarded ignored", headerXForwardedFor, "10.0.0.1:1234", fwd, "10.0.0.1"},
+		{"Forwarded from the client ignored", headerXForwardedFor, "10.0.0.1:1234",
+			map[string][]string{"Forwarded": {"for=192.0.2.60"}, "X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1"},
+		{"Forwarded", headerForwarded, "10.0.0.1:1234",
+			map[string][]string{"Forwarded": {`for=192.0.2.60;proto=https, for="[2001:db8::1]:4711"`}}, "2001:db8::1"},
+		{"X-Forwarded-For ignored", headerForwarded, "10.0.0.1:1234", xff, "10.0.0.1"},
+		{"X-Forwarded-For from the client ignored", headerForwarded, "10.0.0.1:1234",
+			map[string][]string{"Forwarded": {"for=192.0.2.60"}, "X-Forwarded-For": {"198.51.100.1"}}, "192.0.2.60"},
+		{"Forwarded unknown", headerForwarded, "10.0.0.1:1234",
+			map[string][]string{"Forwarded": {"for=unknown"}}, "10.0.0.1"},
+		{"IPv6 loopback proxy", headerXForwardedFor, "[::1]:1234", xff, "198.51.100.1"},
+		{"IPv4-mapped client", headerXForwardedFor, "10.0.0.1:1234",
+			map[string][]string{"X-Forwarded-For": {"::ffff:198.51.100.1"}}, "198.51.100.1"},
+		{"IPv4-mapped CIDR matches", headerXForwardedFor, "192.168.0.5:1234", xff, "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, values := range tt.headers {
+				for _, v := range values {
+					r.Header.Add(k, v)
+				}
+			}
+
+			if got := proxies.resolve(r, tt.header); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedProxiesScheme(t *testing.T) {
+	var proxies trustedProxies
+	err := proxies.Set("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		header     proxyHeader
+		remoteAddr string
+		tls        bool
+		headers    map[string][]string
+		want       string
+	}{
+		{"Plain HTTP", headerXForwardedFor, "203.0.113.7:1234", false, nil, "http"},
+		{"TLS", headerXForwardedFor, "203.0.113.7:1234", true, nil, "https"},
+		{"Untrusted peer's header ignored", headerXForwardedFor, "203.0.113.7:1234", false,
+			map[string][]string{"X-Forwarded-Proto": {"https"}}, "http"},
+		{"X-Forwarded-Proto", headerXForwardedFor, "10.0.0.1:1234", false,
+			map[string][]string{"X-Forwarded-Proto": {"https"}}, "https"},
+		{"X-Forwarded-Proto http", headerXForwardedFor, "10.0.0.1:1234", false,
+			map[string][]string{"X-Forwarded-Proto": {"http"}}, "http"},
+		{"Nearest proxy wins", headerXForwardedFor, "10.0.0.1:1234", false,
+			map[string][]string{"X-Forwarded-Proto": {"https, http"}}, "http"},
+		{"Trusted peer without header", headerXForwardedFor, "10.0.0.1:1234", false, nil, "http"},
+		{"Forwarded ignored", headerXForwardedFor, "10.0.0.1:1234", false,
+			map[string][]string{"Forwarded": {"for=192.0.2.60;proto=https"}}, "http"},
+		{"Forwarded", headerForwarded, "10.0.0.1:1234", false,
+			map[string][]string{"Forwarded": {"for=192.0.2.60;proto=https"}}, "https"},
+		{"Forwarded nearest element", headerForwarded, "10.0.0.1:1234", false,
+			map[string][]string{"Forwarded": {"for=192.0.2.60;proto=http, for=10.0.0.2;proto=HTTPS"}}, "https"},
+		{"X-Forwarded-Proto ignored", headerForwarded, "10.0.0.1:1234", false,
+			map[string][]string{"X-Forwarded-Proto": {"https"}}, "http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "http://example.com/"
+			if tt.tls {
+				target = "https://example.com/"
+			}
+
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			r.RemoteAddr = tt.remoteAddr
+			for k, values := range tt.headers {
+				for _, v := range values {
+					r.Header.Add(k, v)
+				}
+			}
+
+			if got := proxies.scheme(r, tt.header); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
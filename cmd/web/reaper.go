@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// reaper periodically removes expired snippets from the store, either
+// deleting them or moving them to the archive. Get() and Latest() already
+// hide expired snippets, so this is only about keeping the table small.
+type reaper struct {
+	snippets  models.SnippetStore
+	logger    *slog.Logger
+	interval  time.Duration
+	batchSize int
+	archive   bool
+
+	// now returns the current time. It's time.Now in production, but can
+	// be swapped for a fake clock in tests.
+	now func() time.Time
+}
+
+// run reaps expired snippets every r.interval until stop is closed.
+func (r *reaper) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			r.reap(stop)
+		}
+	}
+}
+
+// reap removes every snippet which has expired, one batch at a time so that
+// no single transaction holds locks for too long. It returns the number of
+// snippets removed.
+func (r *reaper) reap(stop <-chan struct{}) int {
+	before := r.now()
+	total := 0
+
+	for {
+		var (
+			n   int
+			err error
+		)
+
+		if r.archive {
+			n, err = r.snippets.ArchiveExpired(before, r.batchSize)
+		} else {
+			n, err = r.snippets.DeleteExpired(before, r.batchSize)
+		}
+
+		if err != nil {
+			r.logger.Error("reaping expired snippets", "error", err.Error())
+			break
+		}
+
+		total += n
+
+		// A short batch means there's nothing left to do. We also stop
+		// early on shutdown rather than working through a big backlog.
+		if n < r.batchSize || stopped(stop) {
+			break
+		}
+	}
+
+	if total > 0 {
+		r.logger.Info("reaped expired snippets", "count", total, "archived", r.archive)
+	}
+
+	return total
+}
+
+// stopped reports whether stop has been closed, without blocking.
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
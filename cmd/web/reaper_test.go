@@ -0,0 +1,111 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// fakeClock is a clock which only moves when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestReaperReap(t *testing.T) {
+	for _, archive := range []bool{false, true} {
+		name := "Delete"
+		if archive {
+			name = "Archive"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+
+			snippets := models.NewMemorySnippetModel()
+			snippets.Now = clock.now
+
+			for _, expires := range []int{1, 1, 1, 7, 7, 365} {
+				_, err := snippets.Insert(models.NewSnippet{
+					Title: "Title", Content: "Content", Expires: expires,
+					Format: models.FormatPlain, Visibility: models.VisibilityPublic,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := &reaper{
+				snippets:  snippets,
+				logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+				batchSize: 2,
+				archive:   archive,
+				now:       clock.now,
+			}
+
+			stop := make(chan struct{})
+
+			tests := []struct {
+				advance time.Duration
+				want    int
+			}{
+				{time.Hour, 0},
+				{2 * 24 * time.Hour, 3},
+				{2 * 24 * time.Hour, 0},
+				{7 * 24 * time.Hour, 2},
+				{365 * 24 * time.Hour, 1},
+			}
+
+			reaped := 0
+			for _, tt := range tests {
+				clock.advance(tt.advance)
+
+				if got := r.reap(stop); got != tt.want {
+					t.Errorf("at %s: reaped %d snippets; want %d", clock.t.Format(time.DateOnly), got, tt.want)
+				}
+				reaped += tt.want
+			}
+
+			wantArchived := 0
+			if archive {
+				wantArchived = reaped
+			}
+			if got := len(snippets.Archived()); got != wantArchived {
+				t.Errorf("got %d archived snippets; want %d", got, wantArchived)
+			}
+		})
+	}
+}
+
+func TestReaperRunStops(t *testing.T) {
+	r := &reaper{
+		snippets:  models.NewMemorySnippetModel(),
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		interval:  time.Millisecond,
+		batchSize: 10,
+		now:       time.Now,
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		r.run(stop)
+		close(done)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reaper didn't stop after stop was closed")
+	}
+}
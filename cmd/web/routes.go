@@ -12,29 +12,74 @@ func (app *application) routes() http.Handler {
 	// then register the home function as the handler for the "/" route
 	mux := http.NewServeMux()
 
-	// Create a file server which serves files out of the "./ui/static" directory.
-	// Note that the path given to the http.Dir function is relative to the project
-	// directory root.
-	fileServer := http.FileServer(http.Dir("./ui/static/"))
-
-	// Use the mux.Handle() method to register the file server as the handler for
-	// all URL paths starting with "/static/". To do this, we use the
-	// http.StripPrefix() function to modify the request URL path before the
-	// file server sees it. If we didn't do this, the file server would look for
-	// files with paths like "./ui/static/static/css/main.css", which obviously
-	// don't exist. By using http.StripPrefix(), the file server will see a path
-	// like "./ui/static/css/main.css", which is correct.
-	mux.Handle("/static/", http.StripPrefix("/static/", fileServer))
-
-	mux.HandleFunc("GET /{$}", app.home)
-	mux.HandleFunc("GET /snippet/view/{id}", app.snippetView)
-	mux.HandleFunc("GET /snippet/create", app.snippetCreate)
-	mux.HandleFunc("POST /snippet/create", app.snippetCreatePost)
+	// Use the http.FileServerFS() function to create a HTTP handler which
+	// serves the static files in app.uiFS (the embedded ui.Files, or ./ui on
+	// disk in -dev mode). Our static files are contained in the "static"
+	// folder of that filesystem, so we don't need to strip the /static/
+	// prefix from the request path.
+	mux.Handle("GET /static/", http.FileServerFS(app.uiFS))
+
+	// The raw and download endpoints are meant for tools like curl, so
+	// unlike the pages they don't use the session. They're still rate
+	// limited, per IP address.
+	limited := alice.New(app.limitRequests)
+
+	mux.Handle("GET /snippet/raw/{id}", limited.ThenFunc(app.snippetRaw))
+	mux.Handle("GET /snippet/download/{id}", limited.ThenFunc(app.snippetDownload))
+
+	// So is the paste endpoint, which creates a snippet from a plain
+	// request body (`curl --data-binary @- host/`). It does its own
+	// same-origin check in place of the csrf middleware.
+	mux.Handle("POST /{$}", limited.ThenFunc(app.snippetPaste))
+
+	// Create a new middleware chain containing the middleware specific to our
+	// dynamic application routes: the LoadAndSave session middleware and the
+	// csrf and authenticate middleware which rely on it, followed by the
+	// rate limiter, which needs to know who's logged in. The static files
+	// don't need any of them, so they're registered without them above.
+	dynamic := alice.New(app.sessionManager.LoadAndSave, app.csrf, app.authenticate,
+		app.limitRequests)
+
+	mux.Handle("GET /{$}", dynamic.ThenFunc(app.home))
+	mux.Handle("GET /snippets", dynamic.ThenFunc(app.snippetList))
+	mux.Handle("GET /search", dynamic.ThenFunc(app.snippetSearch))
+	mux.Handle("GET /tag/{name}", dynamic.ThenFunc(app.snippetTag))
+	mux.Handle("GET /snippet/view/{id}", dynamic.ThenFunc(app.snippetView))
+	mux.Handle("POST /snippet/view/{id}", dynamic.ThenFunc(app.snippetBurnPost))
+	mux.Handle("POST /snippet/unlock/{id}", dynamic.ThenFunc(app.snippetUnlockPost))
+	mux.Handle("GET /snippet/view/{id}/revisions", dynamic.ThenFunc(app.snippetRevisions))
+	mux.Handle("GET /user/signup", dynamic.ThenFunc(app.userSignup))
+	mux.Handle("POST /user/signup", dynamic.ThenFunc(app.userSignupPost))
+	mux.Handle("GET /user/login", dynamic.ThenFunc(app.userLogin))
+	mux.Handle("POST /user/login", dynamic.ThenFunc(app.userLoginPost))
+
+	// Protected (authenticated-only) application routes, using a new
+	// "protected" middleware chain which includes the requireAuthentication
+	// middleware.
+	protected := dynamic.Append(app.requireAuthentication)
+
+	mux.Handle("GET /snippet/create", protected.ThenFunc(app.snippetCreate))
+	mux.Handle("POST /snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	mux.Handle("GET /snippet/edit/{id}", protected.ThenFunc(app.snippetEdit))
+	mux.Handle("POST /snippet/edit/{id}", protected.ThenFunc(app.snippetEditPost))
+	mux.Handle("POST /user/logout", protected.ThenFunc(app.userLogoutPost))
+
+	// The JSON API skips the session, CSRF and authenticate middleware used
+	// by the HTML pages. Its write endpoints authenticate with HTTP Basic
+	// credentials and only accept JSON bodies instead. Every API request
+	// counts against the API rate limit.
+	api := alice.New(app.limitAPI)
+	apiWrite := api.Append(app.requireJSON, app.requireAPIAuthentication)
+
+	mux.Handle("GET /api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	mux.Handle("GET /api/v1/snippets/{id}", api.ThenFunc(app.apiSnippetView))
+	mux.Handle("POST /api/v1/snippets", apiWrite.ThenFunc(app.apiSnippetCreate))
+	mux.Handle("/api/", api.ThenFunc(app.apiNotFound))
 
 	// Create a middleware chain containing our 'standard' middleware
 	// which will be used for every request our application receives.
-	standard := alice.New(app.recoverPanic,
-		app.logRequest, commonHeaders)
+	standard := alice.New(requestID, app.realIP, app.logRequest,
+		app.recoverPanic, commonHeaders)
 
 	// reutnr the 'standard' middleware chain followed the servemux.
 	return standard.Then(mux)
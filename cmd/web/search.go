@@ -0,0 +1,96 @@
+package main
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// searchData is the search page's part of templateData. Terms are the words
+// of Query which were searched for, and are highlighted in the results.
+// NextPage and PrevPage are 0 when there's no such page.
+type searchData struct {
+	Query    string
+	Terms    []string
+	NextPage int
+	PrevPage int
+}
+
+// excerptLength is roughly how many bytes of a snippet's content are shown
+// in a search result.
+const excerptLength = 240
+
+// searchPattern returns a case-insensitive regexp matching any of the search
+// terms, or nil if there aren't any.
+func searchPattern(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// markMatches HTML-escapes text and wraps every occurrence of the search terms
+// in <mark> tags. Only the text is escaped, so the result is safe to output
+// as template.HTML.
+func markMatches(text string, terms []string) template.HTML {
+	re := searchPattern(terms)
+	if re == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+// excerpt returns about excerptLength bytes of content around the first
+// match of the search terms, or from the start if none of them appear in it.
+func excerpt(content string, terms []string) string {
+	if len(content) <= excerptLength {
+		return content
+	}
+
+	start := 0
+	if re := searchPattern(terms); re != nil {
+		if loc := re.FindStringIndex(content); loc != nil {
+			start = max(loc[0]-excerptLength/4, 0)
+		}
+	}
+
+	end := min(start+excerptLength, len(content))
+
+	// Don't cut a multi-byte character in half.
+	for start > 0 && !utf8.RuneStart(content[start]) {
+		start--
+	}
+	for end < len(content) && !utf8.RuneStart(content[end]) {
+		end++
+	}
+
+	s := content[start:end]
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(content) {
+		s += "…"
+	}
+
+	return s
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serverConfig holds the settings for the http.Server, taken from the
+// command-line flags.
+type serverConfig struct {
+	addr              string
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	shutdownTimeout   time.Duration
+
+	// tlsCert and tlsKey are the certificate and key files. When both are
+	// set the server speaks HTTPS only.
+	tlsCert string
+	tlsKey  string
+	// redirectAddr is an optional plain HTTP listener which redirects
+	// every request to HTTPS.
+	redirectAddr string
+}
+
+// tlsEnabled reports whether the server should use HTTPS.
+func (cfg serverConfig) tlsEnabled() bool {
+	return cfg.tlsCert != "" && cfg.tlsKey != ""
+}
+
+// serve runs the HTTP server until it receives a SIGINT or SIGTERM signal.
+// It then stops accepting new connections, gives in-flight requests up to
+// shutdownTimeout to finish, and waits for any background goroutines
+// before returning. It returns nil after a clean shutdown.
+func (app *application) serve(cfg serverConfig) error {
+	srv := &http.Server{
+		Addr:    cfg.addr,
+		Handler: app.routes(),
+		// Send any errors from the server (like TLS handshake failures)
+		// through our structured logger rather than the standard logger.
+		ErrorLog:          slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		ReadTimeout:       cfg.readTimeout,
+		ReadHeaderTimeout: cfg.readHeaderTimeout,
+		WriteTimeout:      cfg.writeTimeout,
+		IdleTimeout:       cfg.idleTimeout,
+	}
+
+	// If TLS is enabled, serve the certificate through a certReloader so
+	// that renewed certificates are picked up without a restart, and
+	// optionally start a second, plain HTTP server which redirects to HTTPS.
+	var redirectSrv *http.Server
+
+	if cfg.tlsEnabled() {
+		reloader, err := newCertReloader(cfg.tlsCert, cfg.tlsKey, app.logger)
+		if err != nil {
+			return err
+		}
+
+		srv.TLSConfig = newTLSConfig(reloader.GetCertificate)
+
+		if cfg.redirectAddr != "" {
+			redirectSrv = &http.Server{
+				Addr:              cfg.redirectAddr,
+				Handler:           redirectToHTTPS(cfg.addr),
+				ErrorLog:          srv.ErrorLog,
+				ReadTimeout:       cfg.readTimeout,
+				ReadHeaderTimeout: cfg.readHeaderTimeout,
+				WriteTimeout:      cfg.writeTimeout,
+				IdleTimeout:       cfg.idleTimeout,
+			}
+		}
+	}
+
+	// shutdownError receives the result of srv.Shutdown() from the
+	// goroutine below.
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+		// Block until a signal is received.
+		s := <-quit
+
+		app.logger.Info("shutting down server", "signal", s.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+		defer cancel()
+
+		// Shutdown() returns nil once every in-flight request has finished,
+		// or an error if the grace period runs out first.
+		if redirectSrv != nil {
+			err := redirectSrv.Shutdown(ctx)
+			if err != nil {
+				app.logger.Error("shutting down redirect server", "error", err.Error())
+			}
+		}
+
+		shutdownErr := srv.Shutdown(ctx)
+
+		app.logger.Info("waiting for background tasks to finish")
+
+		// Tell long-running background workers to stop, then wait for
+		// them (and any other background tasks) to finish. We do this even
+		// if Shutdown() failed, as main() closes the database once we
+		// return. The wait gets a grace period of its own, because the
+		// first one may already be used up.
+		close(app.stop)
+
+		err := app.waitBackground(cfg.shutdownTimeout)
+		if err != nil {
+			app.logger.Error("waiting for background tasks", "error", err.Error())
+		}
+
+		shutdownError <- shutdownErr
+	}()
+
+	if redirectSrv != nil {
+		go func() {
+			app.logger.Info("starting HTTPS redirect server", "addr", redirectSrv.Addr)
+
+			err := redirectSrv.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				app.logger.Error("redirect server", "error", err.Error())
+			}
+		}()
+	}
+
+	app.logger.Info("starting server", "addr", srv.Addr, "tls", cfg.tlsEnabled())
+
+	// ListenAndServe() returns http.ErrServerClosed straight away once
+	// Shutdown() is called, which is what we expect. Anything else is a
+	// real error. The certificate comes from srv.TLSConfig, so we don't
+	// pass any file names to ListenAndServeTLS().
+	var err error
+	if cfg.tlsEnabled() {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+
+	app.logger.Info("stopped server", "addr", srv.Addr)
+
+	return nil
+}
+
+// waitBackground waits for the goroutines started with app.background() to
+// return, giving up after timeout.
+func (app *application) waitBackground(timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("background tasks still running after %s", timeout)
+	}
+}
+
+// background runs fn in a goroutine which the server waits for during a
+// graceful shutdown. Panics are recovered and logged rather than crashing
+// the whole application.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if pv := recover(); pv != nil {
+				app.logger.Error(fmt.Sprintf("%v", pv))
+			}
+		}()
+
+		fn()
+	}()
+}
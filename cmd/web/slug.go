@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// lookupSnippet finds the snippet named by the {id} wildcard in the URL.
+// That's its slug or, for snippets created before slugs were added, its
+// numeric ID. moved reports that the numeric ID was used, in which case
+// the caller should redirect to canonicalURL().
+func (app *application) lookupSnippet(r *http.Request) (s models.Snippet, moved bool, err error) {
+	value := r.PathValue("id")
+
+	// Try the slug first, as a slug can be all digits too.
+	if models.ValidSlug(value) {
+		s, err = app.snippets.GetBySlug(value)
+		if !errors.Is(err, models.ErrNoRecord) {
+			return s, false, err
+		}
+	}
+
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 1 {
+		return models.Snippet{}, false, models.ErrNoRecord
+	}
+
+	s, err = app.snippets.Get(id)
+	if err != nil {
+		return models.Snippet{}, false, err
+	}
+
+	// Newer snippets can only be found by their slug, otherwise anyone
+	// could still count up through the IDs.
+	if !s.HasNumericURL() {
+		return models.Snippet{}, false, models.ErrNoRecord
+	}
+
+	return s, true, nil
+}
+
+// canonicalURL returns the URL of the request with the {id} wildcard
+// replaced by the slug.
+func canonicalURL(r *http.Request, slug string) string {
+	segments := strings.Split(r.URL.Path, "/")
+	for i, segment := range segments {
+		if segment == r.PathValue("id") {
+			segments[i] = slug
+			break
+		}
+	}
+
+	u := *r.URL
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+
+	return u.RequestURI()
+}
+
+// redirectToSlug permanently redirects an old numeric URL to its slug URL.
+// Requests other than GET and HEAD get a 308, so that browsers repeat them
+// with the same method and body.
+func redirectToSlug(w http.ResponseWriter, r *http.Request, slug string) {
+	status := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+
+	http.Redirect(w, r, canonicalURL(r, slug), status)
+}
+
+// resolveSnippet is lookupSnippet() for the HTML handlers. It sends the
+// 404, error or redirect response itself, and returns false if it did.
+// Private snippets the user can't see are a 404 rather than a redirect, as
+// the redirect would give away their slug.
+func (app *application) resolveSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, moved, err := app.lookupSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			http.NotFound(w, r)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return models.Snippet{}, false
+	}
+
+	if moved {
+		if !snippet.VisibleTo(app.authenticatedUserID(r)) {
+			http.NotFound(w, r)
+		} else {
+			redirectToSlug(w, r, snippet.Slug)
+		}
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
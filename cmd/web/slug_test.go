@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// legacySnippets gives every snippet the 12 character hex slug of one
+// created before slugs were added, so that it can still be found by its
+// numeric ID.
+type legacySnippets struct {
+	models.SnippetStore
+}
+
+func legacySlug(id int) string {
+	return fmt.Sprintf("%012x", id)
+}
+
+func (m legacySnippets) Get(id int) (models.Snippet, error) {
+	s, err := m.SnippetStore.Get(id)
+	s.Slug = legacySlug(id)
+	return s, err
+}
+
+func (m legacySnippets) GetBySlug(slug string) (models.Snippet, error) {
+	id, err := strconv.ParseInt(slug, 16, 0)
+	if err != nil || len(slug) != 12 {
+		return models.Snippet{}, models.ErrNoRecord
+	}
+	return m.Get(int(id))
+}
+
+func TestNumericURLs(t *testing.T) {
+	app := newTestApplication(t)
+	store := app.snippets
+	app.snippets = legacySnippets{store}
+	ts := newTestServer(t, app.routes())
+
+	insert := func(s models.NewSnippet) int {
+		t.Helper()
+
+		s.Title, s.Content, s.Expires, s.Format = "Old", "From before slugs", 7, models.FormatPlain
+		slug, err := store.Insert(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snippet, err := store.GetBySlug(slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snippet.ID
+	}
+
+	public := strconv.Itoa(insert(models.NewSnippet{Visibility: models.VisibilityPublic}))
+	private := strconv.Itoa(insert(models.NewSnippet{Visibility: models.VisibilityPrivate, UserID: 1}))
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"View public", "/snippet/view/" + public, http.StatusMovedPermanently, "/snippet/view/" + legacySlug(1)},
+		{"View private", "/snippet/view/" + private, http.StatusNotFound, ""},
+		{"Raw public", "/snippet/raw/" + public, http.StatusMovedPermanently, "/snippet/raw/" + legacySlug(1)},
+		{"Raw private", "/snippet/raw/" + private, http.StatusNotFound, ""},
+		{"API public", "/api/v1/snippets/" + public, http.StatusMovedPermanently, "/api/v1/snippets/" + legacySlug(1)},
+		{"API private", "/api/v1/snippets/" + private, http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, _ := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("got status %d; want %d", code, tt.wantCode)
+			}
+
+			if location := header.Get("Location"); location != tt.wantLocation {
+				t.Errorf("got location %q; want %q", location, tt.wantLocation)
+			}
+		})
+	}
+
+	// The owner of a private snippet is still redirected to it.
+	ts.login(t, app, "alice@example.com", "pa$$word1234")
+
+	code, header, _ := ts.get(t, "/snippet/view/"+private)
+	if code != http.StatusMovedPermanently {
+		t.Errorf("owner: got status %d; want %d", code, http.StatusMovedPermanently)
+	}
+	if location, want := header.Get("Location"), "/snippet/view/"+legacySlug(2); location != want {
+		t.Errorf("owner: got location %q; want %q", location, want)
+	}
+}
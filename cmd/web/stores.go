@@ -0,0 +1,66 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+	"github.com/fatonh/lovrinbox/internal/ratelimit"
+
+	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/sqlite3store"
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+// stores groups together the storage backends picked by the -db-driver
+// flag. db is nil for the "memory" driver. rateLimits keeps the rate
+// limiter's buckets in the database, for -rate-limit-store=db; it's nil
+// when there's no database to share.
+type stores struct {
+	db         *sql.DB
+	snippets   models.SnippetStore
+	users      models.UserStore
+	sessions   scs.Store
+	rateLimits ratelimit.Store
+}
+
+// openStores opens a connection pool for the given driver and wraps it in the
+// matching model implementations. Sessions live in the sessions table of the
+// same database, or in memory when there's no database at all.
+func openStores(driver, dsn string) (*stores, error) {
+	switch driver {
+	case "mysql":
+		db, err := openDB(driver, dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &stores{
+			db:         db,
+			snippets:   &models.SnippetModel{DB: db},
+			users:      &models.UserModel{DB: db},
+			sessions:   mysqlstore.New(db),
+			rateLimits: &models.RateLimitModel{DB: db},
+		}, nil
+	case "sqlite":
+		db, err := openDB(driver, dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &stores{
+			db:         db,
+			snippets:   &models.SQLiteSnippetModel{DB: db},
+			users:      &models.SQLiteUserModel{DB: db},
+			sessions:   sqlite3store.New(db),
+			rateLimits: &models.SQLiteRateLimitModel{DB: db},
+		}, nil
+	case "memory":
+		return &stores{
+			snippets: models.NewMemorySnippetModel(),
+			users:    models.NewMemoryUserModel(),
+			sessions: memstore.New(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+// Limits on the tags of a single snippet.
+const (
+	maxTags      = 10
+	maxTagLength = 30
+)
+
+// tagRX matches a valid tag: lower case letters, digits and a few
+// punctuation characters which are safe in a URL path, like "k8s", "c++"
+// or "node.js".
+var tagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+._-]*$`)
+
+// parseTags splits a comma-separated list of tags, trimming and lower-casing
+// each one and dropping empty entries and duplicates.
+func parseTags(s string) []string {
+	var tags []string
+
+	for tag := range strings.SplitSeq(s, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// validTag reports whether tag can be used as a tag.
+func validTag(tag string) bool {
+	return len(tag) <= maxTagLength && tagRX.MatchString(tag)
+}
+
+// cloudTag is a tag in the home page's tag cloud. Size runs from 1 for the
+// least used tags to 5 for the most used ones.
+type cloudTag struct {
+	Name  string
+	Count int
+	Size  int
+}
+
+// newTagCloud sizes the tags relative to the most used one and sorts them
+// by name.
+func newTagCloud(counts []models.TagCount) []cloudTag {
+	most := 0
+	for _, tc := range counts {
+		most = max(most, tc.Count)
+	}
+
+	cloud := make([]cloudTag, len(counts))
+	for i, tc := range counts {
+		cloud[i] = cloudTag{
+			Name:  tc.Name,
+			Count: tc.Count,
+			Size:  1 + 4*(tc.Count-1)/max(most-1, 1),
+		}
+	}
+
+	slices.SortFunc(cloud, func(a, b cloudTag) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return cloud
+}
@@ -2,9 +2,13 @@ package main
 
 import (
 	"html/template"
+	"io/fs"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/fatonh/lovrinbox/internal/diff"
+	"github.com/fatonh/lovrinbox/internal/highlight"
 	"github.com/fatonh/lovrinbox/internal/models"
 )
 
@@ -15,9 +19,39 @@ import (
 
 // Include a Snippets field in the templateData struct
 type templateData struct {
-	CurrentYear int
-	Snippet     models.Snippet
-	Snippets    []models.Snippet
+	CurrentYear     int
+	Snippet         models.Snippet
+	Snippets        []models.Snippet
+	Form            any
+	Flash           string
+	IsAuthenticated bool
+	CSRFToken       string
+
+	// AuthenticatedUserID lets templates check ownership, e.g. to show an
+	// edit link on the user's own snippets.
+	AuthenticatedUserID int
+
+	// Page describes the current page of a paginated listing.
+	Page pageMetadata
+
+	// Rendered is the sanitized HTML of a Markdown snippet.
+	Rendered template.HTML
+
+	// TagCloud holds the most used tags, for the home page.
+	TagCloud []cloudTag
+
+	// Search holds the query and results shown on the search page.
+	Search searchData
+
+	// Revisions and Diff are used by the revision history page, which
+	// compares the revisions numbered DiffFrom and DiffTo.
+	Revisions []models.Revision
+	Diff      []diff.Line
+	DiffFrom  int
+	DiffTo    int
+	// DiffTooLarge is set when the revisions differ in too many lines to
+	// show the diff.
+	DiffTooLarge bool
 }
 
 // create a humanDate function which returns a nicely formatted string
@@ -31,17 +65,43 @@ func humanDate(t time.Time) string {
 // acts as a lookup table mapping names to functions.
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"diffClass": diffClass,
+	"highlight": markMatches,
+	"excerpt":   excerpt,
+	"join":      strings.Join,
+	// highlightCode renders snippet content with syntax highlighting and
+	// line numbers. languages and languageName are for the language
+	// selector and labels.
+	"highlightCode": highlight.HTML,
+	"languages":     func() []highlight.Language { return highlight.Languages },
+	"languageName":  highlight.Name,
+	// sortOrders lets the listing template offer every sort order.
+	"sortOrders": func() []string { return models.SortOrders },
 }
 
-func newTemplateCache() (map[string]*template.Template, error) {
+// diffClass returns the CSS class used to show a line of a diff.
+func diffClass(op diff.Op) string {
+	switch op {
+	case diff.Insert:
+		return "diff-insert"
+	case diff.Delete:
+		return "diff-delete"
+	default:
+		return "diff-equal"
+	}
+}
+
+// newTemplateCache parses every page template in fsys, along with the base
+// layout and partials, into a map keyed by page name. fsys is normally the
+// embedded ui.Files, or the ./ui directory on disk in -dev mode.
+func newTemplateCache(fsys fs.FS) (map[string]*template.Template, error) {
 	// Initialize a new map to act as cache.
 	cache := map[string]*template.Template{}
 
-	// Use the filepath.Glob() function to get a slice of all filepaths
-	// with the extension '.tmpl' in the './ui/html/pages' directory
-	// this will essenctially five us a slice of all the filepaths for our
-	// applicaiton's page templates.
-	pages, err := filepath.Glob("./ui/html/pages/*.tmpl")
+	// Use fs.Glob() to get a slice of all filepaths in the fsys filesystem
+	// that match the pattern 'html/pages/*.tmpl'. This essentially gives
+	// us a slice of all the 'page' templates for the application.
+	pages, err := fs.Glob(fsys, "html/pages/*.tmpl")
 	if err != nil {
 		return nil, err
 	}
@@ -52,43 +112,23 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		// and use that as the name of the template set
 		name := filepath.Base(page)
 
-		// The template.FuncMap() must be registered with the template set before you
-		// call the ParseFiles() method. This means we have to use
-		// template.New() to create a new, empty template set,
-		// use the Funcs() method to register the FuncMap, and then
-		// parse the files as normal.
-		ts, err := template.New(name).Funcs(functions).ParseFiles("./ui/html/base.tmpl")
-		if err != nil {
-			return nil, err
-		}
-
-		// Call the ParseGlob() *on this template set* to add any partials.
-		ts, err = ts.ParseGlob("./ui/html/partials/*.tmpl")
-		if err != nil {
-			return nil, err
+		// Create a slice containing the filepath patterns for the templates we
+		// want to parse.
+		patterns := []string{
+			"html/base.tmpl",
+			"html/partials/*.tmpl",
+			page,
 		}
 
-		// Call ParseFile() *on this template set* to add the page
-		// template.
-		ts, err = ts.ParseFiles(page)
+		// The template.FuncMap must be registered with the template set
+		// before parsing, so we create an empty template set with
+		// template.New(), register the FuncMap with Funcs(), and then use
+		// ParseFS() to parse the template files from fsys.
+		ts, err := template.New(name).Funcs(functions).ParseFS(fsys, patterns...)
 		if err != nil {
 			return nil, err
 		}
 
-		// // Create a slice containing the filepaths for our base template,
-		// // any partials and the page.
-		// file := []string{
-		// 	"./ui/html/base.tmpl",
-		// 	"./ui/html/partials/nav.tmpl",
-		// 	page,
-		// }
-
-		// // Parse the files into a tempalte set.
-		// ts, err := template.ParseFiles(file...)
-		// if err != nil {
-		// 	return nil, err
-		// }
-
 		// Add the template set to the map as normal
 		cache[name] = ts
 	}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+	"github.com/fatonh/lovrinbox/internal/ratelimit"
+	"github.com/fatonh/lovrinbox/ui"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/alexedwards/scs/v2/memstore"
+)
+
+// newTestApplication returns an application backed by the in-memory stores,
+// so that handler tests don't need a database. Rate limiting is off and log
+// output is thrown away.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	templateCache, err := newTemplateCache(ui.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionManager := scs.New()
+	sessionManager.Store = memstore.New()
+
+	return &application{
+		logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		snippets:       models.NewMemorySnippetModel(),
+		users:          models.NewMemoryUserModel(),
+		templateCache:  templateCache,
+		uiFS:           ui.Files,
+		sessionManager: sessionManager,
+		rendered:       newRenderCache(renderCacheSize),
+		unlockGuesses:  newGuessLimiter(maxUnlockGuesses, unlockWindow),
+		limiter:        ratelimit.NewMemoryStore(),
+		stop:           make(chan struct{}),
+	}
+}
+
+// testServer wraps httptest.Server with a client which keeps cookies, so
+// that the session survives between requests, and doesn't follow
+// redirects, so that tests can check them.
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	t.Helper()
+
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts.Client().Jar = jar
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// get makes a GET request and returns the status code, headers and body.
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	t.Helper()
+
+	rs, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(body))
+}
+
+// postForm is like get() for a POST of form data.
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	t.Helper()
+
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(bytes.TrimSpace(body))
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+?)'>`)
+
+// extractCSRFToken finds the CSRF token in the hidden field of a form.
+func extractCSRFToken(t *testing.T, body string) string {
+	t.Helper()
+
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+
+	return html.UnescapeString(matches[1])
+}
+
+// login signs up a user straight through the store, then logs in through
+// the login form so that the test server's session is authenticated.
+func (ts *testServer) login(t *testing.T, app *application, email, password string) {
+	t.Helper()
+
+	err := app.users.Insert("Test", email, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("logging in: got status %d; want %d", code, http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloadInterval is how often certReloader checks the certificate files
+// for changes.
+const certReloadInterval = 10 * time.Second
+
+// newTLSConfig returns a hardened tls.Config. We only allow TLS 1.2 and
+// above, prefer the curves which have assembly implementations, and limit
+// TLS 1.2 to AEAD cipher suites with forward secrecy. (TLS 1.3 suites
+// aren't configurable and are all fine.)
+func newTLSConfig(getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)) *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+		GetCertificate: getCertificate,
+	}
+}
+
+// certReloader serves a certificate loaded from disk and picks up new
+// versions of the files when they change (e.g. after a certbot renewal),
+// so the server doesn't need restarting. The files are checked lazily during
+// handshakes, at most once every certReloadInterval.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// newCertReloader loads the certificate and key for the first time. Unlike
+// later reloads, a failure here is returned as an error.
+func newCertReloader(certFile, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+	}
+
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.checked = time.Now()
+
+	return cr, nil
+}
+
+// latestModTime returns the most recent modification time of the two files.
+func (cr *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, name := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// GetCertificate is used as tls.Config.GetCertificate.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	if time.Since(cr.checked) >= certReloadInterval {
+		cr.checked = time.Now()
+		cr.reload()
+	}
+
+	return cr.cert, nil
+}
+
+// reload loads the files again if they've changed. If anything goes wrong
+// (say we catch the files half way through being replaced) we log it and
+// keep serving the previous certificate. The caller must hold cr.mu.
+func (cr *certReloader) reload() {
+	modTime, err := cr.latestModTime()
+	if err != nil {
+		cr.logger.Error("checking TLS certificate", "error", err.Error())
+		return
+	}
+
+	if !modTime.After(cr.modTime) {
+		return
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		cr.logger.Error("reloading TLS certificate", "error", err.Error())
+		return
+	}
+
+	cr.cert = &cert
+	cr.modTime = modTime
+
+	cr.logger.Info("reloaded TLS certificate", "cert", cr.certFile)
+}
+
+// redirectToHTTPS returns a handler which permanently redirects every
+// request to the same URL on the HTTPS listener at httpsAddr.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			// r.Host didn't include a port.
+			host = r.Host
+		}
+
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		url := "https://" + host + r.URL.RequestURI()
+
+		// Use a 308 rather than a 301 so that clients keep the method and
+		// body of non-GET requests.
+		http.Redirect(w, r, url, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+	"github.com/fatonh/lovrinbox/internal/validator"
+)
+
+// Wrong passwords for a password-protected snippet are limited to
+// maxUnlockGuesses per client and snippet in each unlockWindow. bcrypt
+// already makes every guess slow, this stops anyone making a lot of them.
+const (
+	maxUnlockGuesses = 5
+	unlockWindow     = 15 * time.Minute
+)
+
+// maxUnlockedSnippets caps how many unlocked snippets are remembered in a
+// session, so that the session can't grow without limit.
+const maxUnlockedSnippets = 50
+
+// unlockedSessionKey is the session key holding the IDs of the
+// password-protected snippets the session has unlocked.
+const unlockedSessionKey = "unlockedSnippets"
+
+// guessLimiter counts failed guesses per key in fixed windows.
+type guessLimiter struct {
+	mu      sync.Mutex
+	guesses map[string]guessCount
+	limit   int
+	window  time.Duration
+	now     func() time.Time
+}
+
+type guessCount struct {
+	count int
+	reset time.Time
+}
+
+func newGuessLimiter(limit int, window time.Duration) *guessLimiter {
+	return &guessLimiter{
+		guesses: make(map[string]guessCount),
+		limit:   limit,
+		window:  window,
+		now:     time.Now,
+	}
+}
+
+// take uses up one of the key's guesses, reporting whether it had any left.
+// If not, it also returns how long until it gets some more. Checking and
+// counting happen together, before the password is checked, so that
+// guesses made in parallel can't all get in while bcrypt is busy.
+func (l *guessLimiter) take(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	// Forget about windows which have ended, so that the map doesn't grow
+	// without limit.
+	for k, g := range l.guesses {
+		if !now.Before(g.reset) {
+			delete(l.guesses, k)
+		}
+	}
+
+	g, ok := l.guesses[key]
+	if !ok {
+		g = guessCount{reset: now.Add(l.window)}
+	}
+
+	if g.count >= l.limit {
+		return false, g.reset.Sub(now)
+	}
+
+	g.count++
+	l.guesses[key] = g
+
+	return true, 0
+}
+
+// reset forgets the key's guesses, once it has got the password right.
+func (l *guessLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.guesses, key)
+}
+
+// isUnlocked reports whether the session has unlocked the snippet with the
+// given ID.
+func (app *application) isUnlocked(r *http.Request, id int) bool {
+	ids, _ := app.sessionManager.Get(r.Context(), unlockedSessionKey).([]int)
+	return slices.Contains(ids, id)
+}
+
+// canRead reports whether the user making the request may read the content
+// of the snippet: it must be visible to them and, if it has a password,
+// they must own it or have unlocked it.
+func (app *application) canRead(r *http.Request, s models.Snippet) bool {
+	userID := app.authenticatedUserID(r)
+	if !s.VisibleTo(userID) {
+		return false
+	}
+
+	return !s.Locked(userID) || app.isUnlocked(r, s.ID)
+}
+
+// readableSnippet fetches the snippet with the ID in the URL, for handlers
+// other than snippetView. Snippets the user can't see are a 404, and
+// password-protected ones which haven't been unlocked redirect to
+// snippetView, which asks for the password. It returns false if it has
+// sent a response.
+func (app *application) readableSnippet(w http.ResponseWriter, r *http.Request) (models.Snippet, bool) {
+	snippet, ok := app.resolveSnippet(w, r)
+	if !ok {
+		return models.Snippet{}, false
+	}
+
+	if !snippet.VisibleTo(app.authenticatedUserID(r)) {
+		http.NotFound(w, r)
+		return models.Snippet{}, false
+	}
+
+	if !app.canRead(r, snippet) {
+		http.Redirect(w, r, "/snippet/view/"+snippet.Slug, http.StatusSeeOther)
+		return models.Snippet{}, false
+	}
+
+	return snippet, true
+}
+
+// snippetUnlockForm holds the form data for unlocking a password-protected
+// snippet. The password is never sent back to the browser.
+type snippetUnlockForm struct {
+	Slug     string
+	Password string
+	validator.Validator
+}
+
+// renderUnlock shows the password form for a snippet in place of its
+// content.
+func (app *application) renderUnlock(w http.ResponseWriter, r *http.Request, status int, form snippetUnlockForm) {
+	data := app.newTemplateData(r)
+	data.Snippet = models.Snippet{Slug: form.Slug}
+	data.Form = snippetUnlockForm{Slug: form.Slug, Validator: form.Validator}
+
+	noStore(w)
+	app.render(w, r, status, "unlock.tmpl", data)
+}
+
+// snippetUnlockPost checks the password for a password-protected snippet.
+// If it's right, the snippet is unlocked for the rest of the session.
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.resolveSnippet(w, r)
+	if !ok {
+		return
+	}
+	id := snippet.ID
+	view := "/snippet/view/" + snippet.Slug
+
+	if !snippet.VisibleTo(app.authenticatedUserID(r)) {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Nothing to do if the snippet isn't locked for this user.
+	if app.canRead(r, snippet) {
+		http.Redirect(w, r, view, http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, r, http.StatusBadRequest)
+		return
+	}
+
+	form := snippetUnlockForm{
+		Slug:     snippet.Slug,
+		Password: r.PostForm.Get("password"),
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		app.renderUnlock(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	key := fmt.Sprintf("%d/%s", id, clientIP(r))
+
+	ok, retryAfter := app.unlockGuesses.take(key)
+	if !ok {
+		minutes := int(math.Ceil(retryAfter.Minutes()))
+		form.AddNonFieldError(fmt.Sprintf("Too many wrong passwords. Please try again in %d minutes.", minutes))
+
+		setRetryAfter(w, retryAfter)
+		app.renderUnlock(w, r, http.StatusTooManyRequests, form)
+		return
+	}
+
+	err = snippet.CheckPassword(form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			app.logger.WarnContext(r.Context(), "wrong snippet password", "id", id, "ip", clientIP(r))
+
+			form.AddNonFieldError("The password is incorrect")
+			app.renderUnlock(w, r, http.StatusUnprocessableEntity, form)
+		} else {
+			app.serverError(w, r, err)
+		}
+		return
+	}
+
+	app.unlockGuesses.reset(key)
+
+	// Remember the snippet in the session, dropping the oldest ones once
+	// there are too many.
+	ids, _ := app.sessionManager.Get(r.Context(), unlockedSessionKey).([]int)
+	ids = append(ids, id)
+	if len(ids) > maxUnlockedSnippets {
+		ids = ids[len(ids)-maxUnlockedSnippets:]
+	}
+	app.sessionManager.Put(r.Context(), unlockedSessionKey, ids)
+
+	http.Redirect(w, r, view, http.StatusSeeOther)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatonh/lovrinbox/internal/models"
+)
+
+func TestGuessLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	l := newGuessLimiter(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if ok, _ := l.take("a"); !ok {
+			t.Fatalf("guess %d: got no guesses left", i+1)
+		}
+	}
+
+	ok, retryAfter := l.take("a")
+	if ok {
+		t.Fatal("guess 4: got a guess past the limit")
+	}
+	if retryAfter != time.Minute {
+		t.Errorf("got retry after %v; want %v", retryAfter, time.Minute)
+	}
+
+	// Other keys have their own guesses.
+	if ok, _ := l.take("b"); !ok {
+		t.Error("other key: got no guesses left")
+	}
+
+	// Guesses come back once the window is over.
+	now = now.Add(time.Minute)
+	if ok, _ := l.take("a"); !ok {
+		t.Error("next window: got no guesses left")
+	}
+
+	// Getting the password right starts the count again.
+	l.take("a")
+	l.take("a")
+	l.reset("a")
+	for i := range 3 {
+		if ok, _ := l.take("a"); !ok {
+			t.Fatalf("after reset, guess %d: got no guesses left", i+1)
+		}
+	}
+}
+
+func TestSnippetUnlockConcurrent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	slug, err := app.snippets.Insert(models.NewSnippet{
+		Title: "Locked", Content: "The treasure is under the oak", Expires: 7,
+		Format: models.FormatPlain, Visibility: models.VisibilityPassword,
+		Password: "correct horse battery staple",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/snippet/view/"+slug)
+
+	form := url.Values{}
+	form.Add("password", "wrong password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// Fire all the wrong guesses at once, so that they're checking the
+	// password at the same time. Only maxUnlockGuesses may get that far.
+	const guesses = maxUnlockGuesses * 4
+
+	var wg sync.WaitGroup
+	codes := make(chan int, guesses)
+	errs := make(chan error, guesses)
+
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rs, err := ts.Client().PostForm(ts.URL+"/snippet/unlock/"+slug, form)
+			if err != nil {
+				errs <- err
+				return
+			}
+			rs.Body.Close()
+			codes <- rs.StatusCode
+		}()
+	}
+
+	wg.Wait()
+	close(codes)
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	counts := make(map[int]int)
+	for code := range codes {
+		counts[code]++
+	}
+
+	if counts[http.StatusUnprocessableEntity] != maxUnlockGuesses {
+		t.Errorf("got %d wrong password responses; want %d", counts[http.StatusUnprocessableEntity], maxUnlockGuesses)
+	}
+	if counts[http.StatusTooManyRequests] != guesses-maxUnlockGuesses {
+		t.Errorf("got %d too many requests responses; want %d", counts[http.StatusTooManyRequests], guesses-maxUnlockGuesses)
+	}
+
+	// The right password is turned away too, until the window is over.
+	form.Set("password", "correct horse battery staple")
+	code, _, _ := ts.postForm(t, "/snippet/unlock/"+slug, form)
+	if code != http.StatusTooManyRequests {
+		t.Errorf("right password: got status %d; want %d", code, http.StatusTooManyRequests)
+	}
+}
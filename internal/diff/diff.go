@@ -0,0 +1,248 @@
+package diff
+
+import (
+	"errors"
+	"strings"
+)
+
+// Op says what happened to a line between the old and new text.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Line is one line of a diff. OldNumber and NewNumber are the 1-based line
+// numbers in the old and new text, or 0 when the line isn't in that text
+// (i.e. OldNumber is 0 for insertions and NewNumber is 0 for deletions).
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+}
+
+// MaxChangedLines caps the number of lines Lines() will diff once any
+// lines the two texts start and end with are set aside. Myers' algorithm
+// takes time proportional to the number of lines times the number of
+// changes, so without a cap a large rewrite is an easy way to tie up the
+// server.
+const MaxChangedLines = 5000
+
+// ErrTooLarge is returned by Lines() when the texts differ in too many
+// lines to diff.
+var ErrTooLarge = errors.New("diff: too many changed lines")
+
+// Lines returns a line-based diff between a and b, using Myers' algorithm
+// to find the shortest edit script. Lines are compared exactly, and "\r\n"
+// line endings are treated the same as "\n". It returns ErrTooLarge if
+// more than MaxChangedLines lines would have to be compared.
+func Lines(a, b string) ([]Line, error) {
+	d := &differ{a: splitLines(a), b: splitLines(b)}
+
+	prefix := commonPrefix(d.a, d.b)
+	suffix := commonSuffix(d.a[prefix:], d.b[prefix:])
+	if len(d.a)+len(d.b)-2*(prefix+suffix) > MaxChangedLines {
+		return nil, ErrTooLarge
+	}
+
+	d.compare(0, len(d.a), 0, len(d.b))
+
+	return d.lines, nil
+}
+
+// splitLines splits s into lines without their line endings. An empty
+// string has no lines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+
+	return strings.Split(s, "\n")
+}
+
+// commonPrefix returns how many lines a and b start with in common.
+func commonPrefix(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// commonSuffix returns how many lines a and b end with in common.
+func commonSuffix(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+// differ holds the two texts being compared and the diff lines produced so
+// far, which are appended in order.
+type differ struct {
+	a, b  []string
+	lines []Line
+}
+
+func (d *differ) equal(x, y int) {
+	d.lines = append(d.lines, Line{Op: Equal, Text: d.a[x], OldNumber: x + 1, NewNumber: y + 1})
+}
+
+func (d *differ) delete(x int) {
+	d.lines = append(d.lines, Line{Op: Delete, Text: d.a[x], OldNumber: x + 1})
+}
+
+func (d *differ) insert(y int) {
+	d.lines = append(d.lines, Line{Op: Insert, Text: d.b[y], NewNumber: y + 1})
+}
+
+// compare diffs a[aLo:aHi] against b[bLo:bHi]. This is the linear space
+// version of Myers' algorithm: rather than keeping every frontier to trace
+// the path back, it finds a point on the path (see bisect()) and diffs the
+// two halves on either side of it separately.
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	prefix := commonPrefix(d.a[aLo:aHi], d.b[bLo:bHi])
+	for i := range prefix {
+		d.equal(aLo+i, bLo+i)
+	}
+	aLo += prefix
+	bLo += prefix
+
+	suffix := commonSuffix(d.a[aLo:aHi], d.b[bLo:bHi])
+	aHi -= suffix
+	bHi -= suffix
+
+	switch {
+	case aLo == aHi:
+		for y := bLo; y < bHi; y++ {
+			d.insert(y)
+		}
+	case bLo == bHi:
+		for x := aLo; x < aHi; x++ {
+			d.delete(x)
+		}
+	default:
+		x, y, ok := d.bisect(aLo, aHi, bLo, bHi)
+		if ok {
+			d.compare(aLo, x, bLo, y)
+			d.compare(x, aHi, y, bHi)
+		} else {
+			// Nothing in common at all.
+			for x := aLo; x < aHi; x++ {
+				d.delete(x)
+			}
+			for y := bLo; y < bHi; y++ {
+				d.insert(y)
+			}
+		}
+	}
+
+	for i := range suffix {
+		d.equal(aHi+i, bHi+i)
+	}
+}
+
+// bisect runs Myers' algorithm forwards from the start and backwards from
+// the end of a[aLo:aHi] and b[bLo:bHi] at the same time, until the two
+// paths meet. The point where they meet is on a shortest edit path, so the
+// diff can be split there. Only the current frontier of each search is
+// kept, so this uses O(N+M) memory. It returns false if the paths only meet
+// at the corners, when the ranges have no lines in common.
+func (d *differ) bisect(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+
+	// forward[offset+k] is the furthest x reached on diagonal k from the
+	// start, and backward[offset+k] the same from the end, or -1 if the
+	// diagonal hasn't been reached yet.
+	forward := make([]int, 2*maxD+1)
+	backward := make([]int, 2*maxD+1)
+	for i := range forward {
+		forward[i] = -1
+		backward[i] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	// If the difference in length is odd, the paths meet while extending
+	// the forward one, otherwise while extending the backward one.
+	delta := n - m
+	front := delta%2 != 0
+
+	// Diagonals which have run off the edge of the grid are skipped.
+	var kStart1, kEnd1, kStart2, kEnd2 int
+
+	for step := 0; step < maxD; step++ {
+		for k := -step + kStart1; k <= step-kEnd1; k += 2 {
+			var x int
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				kEnd1 += 2
+			case y > m:
+				kStart1 += 2
+			case front:
+				i := offset + delta - k
+				if i >= 0 && i < len(backward) && backward[i] != -1 && x >= n-backward[i] {
+					return aLo + x, bLo + y, true
+				}
+			}
+		}
+
+		for k := -step + kStart2; k <= step-kEnd2; k += 2 {
+			var x int
+			if k == -step || (k != step && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			switch {
+			case x > n:
+				kEnd2 += 2
+			case y > m:
+				kStart2 += 2
+			case !front:
+				i := offset + delta - k
+				if i >= 0 && i < len(forward) && forward[i] != -1 {
+					fx := forward[i]
+					fy := fx - (delta - k)
+					if fx >= n-x {
+						return aLo + fx, bLo + fy, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// render writes a diff the way `diff -u` would, without the header, so the
+// expected output in tests is easy to read.
+func render(lines []Line) string {
+	var sb strings.Builder
+	for _, l := range lines {
+		switch l.Op {
+		case Equal:
+			sb.WriteString(" ")
+		case Insert:
+			sb.WriteString("+")
+		case Delete:
+			sb.WriteString("-")
+		}
+		sb.WriteString(l.Text + "\n")
+	}
+	return sb.String()
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"Both empty", "", "", ""},
+		{"Identical", "a\nb\n", "a\nb\n", " a\n b\n"},
+		{"All inserted", "", "a\nb", "+a\n+b\n"},
+		{"All deleted", "a\nb", "", "-a\n-b\n"},
+		{"Changed line", "a\nb\nc", "a\nx\nc", " a\n-b\n+x\n c\n"},
+		{"Insert at start", "b\nc", "a\nb\nc", "+a\n b\n c\n"},
+		{"Delete at end", "a\nb\nc", "a\nb", " a\n b\n-c\n"},
+		{"Nothing in common", "a\nb", "c\nd", "-a\n-b\n+c\n+d\n"},
+		{"CRLF line endings", "a\r\nb\r\n", "a\nb\n", " a\n b\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := Lines(tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := render(lines); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLinesShortest checks the example from Myers' paper, which has several
+// shortest edit scripts, by the number of edits rather than the exact diff.
+func TestLinesShortest(t *testing.T) {
+	lines, err := Lines("a\nb\nc\na\nb\nb\na", "c\nb\na\nb\na\nc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edits := 0
+	for _, l := range lines {
+		if l.Op != Equal {
+			edits++
+		}
+	}
+
+	if edits != 5 {
+		t.Errorf("got %d edits; want 5:\n%s", edits, render(lines))
+	}
+}
+
+// TestLinesRandom checks on random inputs that the diff turns a into b and
+// numbers the lines correctly.
+func TestLinesRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	random := func() []string {
+		lines := make([]string, r.Intn(40))
+		for i := range lines {
+			lines[i] = fmt.Sprint(r.Intn(5))
+		}
+		return lines
+	}
+
+	for range 2000 {
+		a, b := random(), random()
+
+		lines, err := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var gotA, gotB []string
+		for _, l := range lines {
+			if l.Op != Insert {
+				gotA = append(gotA, l.Text)
+				if l.OldNumber != len(gotA) {
+					t.Fatalf("line %q has old number %d; want %d", l.Text, l.OldNumber, len(gotA))
+				}
+			}
+			if l.Op != Delete {
+				gotB = append(gotB, l.Text)
+				if l.NewNumber != len(gotB) {
+					t.Fatalf("line %q has new number %d; want %d", l.Text, l.NewNumber, len(gotB))
+				}
+			}
+		}
+
+		if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+			t.Fatalf("diff of %q and %q doesn't reproduce them:\n%s", a, b, render(lines))
+		}
+	}
+}
+
+func TestLinesTooLarge(t *testing.T) {
+	numbered := func(prefix string, n int) string {
+		var sb strings.Builder
+		for i := range n {
+			fmt.Fprintf(&sb, "%s%d\n", prefix, i)
+		}
+		return sb.String()
+	}
+
+	// A small change in a long text is fine, as the unchanged lines at the
+	// start and end don't count.
+	long := numbered("line ", 2*MaxChangedLines)
+	_, err := Lines(long, strings.Replace(long, "line 10\n", "changed\n", 1))
+	if err != nil {
+		t.Errorf("small change: got error %v; want nil", err)
+	}
+
+	half := MaxChangedLines / 2
+	_, err = Lines(numbered("a", half+1), numbered("b", half+1))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("rewrite: got error %v; want ErrTooLarge", err)
+	}
+}
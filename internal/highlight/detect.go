@@ -0,0 +1,113 @@
+package highlight
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+// shebangs maps the interpreter named on a #! line to a language.
+var shebangs = map[string]string{
+	"bash":    "bash",
+	"sh":      "bash",
+	"zsh":     "bash",
+	"python":  "python",
+	"python3": "python",
+	"ruby":    "ruby",
+	"node":    "javascript",
+	"php":     "php",
+}
+
+// rule is a heuristic for recognising a language. A rule matches if any of
+// its patterns match the content.
+type rule struct {
+	language string
+	patterns []*regexp.Regexp
+}
+
+func patterns(exprs ...string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(exprs))
+	for i, expr := range exprs {
+		res[i] = regexp.MustCompile(expr)
+	}
+	return res
+}
+
+// rules are checked in order, so the more specific rules come first. For
+// example TypeScript is checked before JavaScript, and C++ before C.
+var rules = []rule{
+	{"diff", patterns(`(?m)^@@ -\d+(,\d+)? \+\d+(,\d+)? @@`, `(?m)^diff --git `)},
+	{"php", patterns(`<\?php`)},
+	{"go", patterns(`(?m)^package \w+\s*$`, `(?m)^func (\(\w+ \*?\w+\) )?\w+\(.*\) .*\{$`)},
+	{"rust", patterns(`(?m)^\s*fn \w+(<.*>)?\(.*\)( -> .+)? \{`, `(?m)^\s*(pub )?(struct|enum|impl|trait) \w+`, `(?m)^use \w+::`)},
+	{"docker", patterns(`(?m)^FROM \S+`)},
+	{"html", patterns(`(?i)<!doctype html`, `(?i)<html[\s>]`, `(?i)<(div|body|head|span|p|a|script)[\s>]`)},
+	{"xml", patterns(`^<\?xml `)},
+	{"sql", patterns(`(?i)^\s*(SELECT .+ FROM|INSERT INTO|UPDATE \w+ SET|DELETE FROM|CREATE (TABLE|INDEX|VIEW)|ALTER TABLE|DROP TABLE)\b`)},
+	{"python", patterns(`(?m)^\s*def \w+\(.*\):\s*$`, `(?m)^\s*class \w+(\(.*\))?:\s*$`, `(?m)^(from \w+(\.\w+)* )?import \w+`, `(?m)^if __name__ == .__main__.:`)},
+	{"csharp", patterns(`(?m)^using System(\.\w+)*;`, `(?m)^\s*namespace \w+(\.\w+)*\s*\{?$`)},
+	{"java", patterns(`(?m)^\s*(public|private|protected) (static )?(final )?(class|interface|enum) \w+`, `(?m)^import java\.`)},
+	{"kotlin", patterns(`(?m)^\s*fun \w+\(.*\)`, `(?m)^\s*(val|var) \w+(: \w+)? =`)},
+	{"cpp", patterns(`#include <(iostream|vector|string|map|memory)>`, `std::`, `(?m)^\s*template\s*<`)},
+	{"c", patterns(`(?m)^#include [<"]`, `(?m)^int main\(`)},
+	{"typescript", patterns(`(?m)^\s*(export )?(interface|type) \w+`, `(?m)^\s*(const|let) \w+: \w+`)},
+	{"javascript", patterns(`(?m)^\s*(const|let|var) \w+ = `, `(?m)^\s*function \w*\(`, `=> \{`, `console\.log\(`, `require\(['"]`)},
+	{"ruby", patterns(`(?m)^\s*def \w+[?!]?(\(.*\))?\s*$`, `(?m)^\s*end\s*$`, `(?m)^require ['"]`)},
+	{"bash", patterns(`(?m)^\s*(echo|export|cd|sudo|apt(-get)?|curl|mkdir) `, `(?m)^\s*if \[\[? `, `(?m)^\$ `)},
+	{"css", patterns(`(?m)^[\w.#:\-\[\], >*]+\s*\{\s*$`)},
+	{"yaml", patterns(`(?m)^---\s*$`, `(?m)^[\w-]+:( .+)?\n(\s*[\w-]+:|\s+- )`)},
+	{"toml", patterns(`(?m)^\[[\w.-]+\]\s*$`)},
+	{"markdown", patterns(`(?m)^#{1,6} \S`, "(?m)^```", `(?m)^\s*[-*] \S`)},
+}
+
+// Detect guesses the language of content, returning a language ID. It looks
+// at a #! line, then tries to parse the content as JSON, and then works
+// through a list of telltale patterns. If none of those match, it asks
+// chroma, and finally gives up and returns PlainText.
+func Detect(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return PlainText
+	}
+
+	if rest, ok := strings.CutPrefix(trimmed, "#!"); ok {
+		line, _, _ := strings.Cut(rest, "\n")
+		fields := strings.Fields(line)
+		if len(fields) > 0 {
+			interpreter := fields[len(fields)-1]
+			// Handle both "#!/bin/bash" and "#!/usr/bin/env bash".
+			interpreter = interpreter[strings.LastIndex(interpreter, "/")+1:]
+			if language, ok := shebangs[interpreter]; ok {
+				return language
+			}
+		}
+	}
+
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+
+	for _, r := range rules {
+		for _, re := range r.patterns {
+			if re.MatchString(content) {
+				return r.language
+			}
+		}
+	}
+
+	if lexer := lexers.Analyse(content); lexer != nil {
+		config := lexer.Config()
+		if Known(strings.ToLower(config.Name)) {
+			return strings.ToLower(config.Name)
+		}
+		for _, alias := range config.Aliases {
+			if Known(alias) {
+				return alias
+			}
+		}
+	}
+
+	return PlainText
+}
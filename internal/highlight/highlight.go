@@ -0,0 +1,138 @@
+// Package highlight renders snippet content as syntax-highlighted HTML.
+//
+// The HTML uses CSS classes rather than inline styles, because the
+// Content-Security-Policy header doesn't allow inline styles. The classes are
+// styled by ui/static/css/highlight.css, which holds the CSS chroma
+// generates for its "github" style.
+package highlight
+
+import (
+	"bytes"
+	"html/template"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Language is a language which snippets can be highlighted as. ID is what's
+// stored in the database, and the name of the chroma lexer used. Ext is the
+// file name extension used when a snippet is downloaded.
+type Language struct {
+	ID   string
+	Name string
+	Ext  string
+}
+
+// PlainText is the ID of the language which isn't highlighted at all.
+const PlainText = "plaintext"
+
+// Languages lists the languages users can pick from, in the order they're
+// offered.
+var Languages = []Language{
+	{PlainText, "Plain text", "txt"},
+	{"bash", "Bash", "sh"},
+	{"c", "C", "c"},
+	{"cpp", "C++", "cpp"},
+	{"csharp", "C#", "cs"},
+	{"css", "CSS", "css"},
+	{"diff", "Diff", "diff"},
+	{"docker", "Dockerfile", "dockerfile"},
+	{"go", "Go", "go"},
+	{"html", "HTML", "html"},
+	{"java", "Java", "java"},
+	{"javascript", "JavaScript", "js"},
+	{"json", "JSON", "json"},
+	{"kotlin", "Kotlin", "kt"},
+	{"markdown", "Markdown", "md"},
+	{"php", "PHP", "php"},
+	{"python", "Python", "py"},
+	{"ruby", "Ruby", "rb"},
+	{"rust", "Rust", "rs"},
+	{"sql", "SQL", "sql"},
+	{"toml", "TOML", "toml"},
+	{"typescript", "TypeScript", "ts"},
+	{"xml", "XML", "xml"},
+	{"yaml", "YAML", "yaml"},
+}
+
+// Known reports whether id is the ID of one of the Languages.
+func Known(id string) bool {
+	return slices.ContainsFunc(Languages, func(l Language) bool {
+		return l.ID == id
+	})
+}
+
+// Name returns the display name of the language with the given ID, or an
+// empty string if there's no such language.
+func Name(id string) string {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l.Name
+		}
+	}
+	return ""
+}
+
+// Ext returns the file name extension for the language with the given ID,
+// or "txt" if there's no such language.
+func Ext(id string) string {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l.Ext
+		}
+	}
+	return "txt"
+}
+
+// formatter writes a table with the line numbers in the first column, so
+// that selecting the code doesn't select the line numbers too.
+var formatter = html.New(
+	html.WithClasses(true),
+	html.WithLineNumbers(true),
+	html.LineNumbersInTable(true),
+	html.TabWidth(4),
+)
+
+var style = styles.Get("github")
+
+// HTML highlights content as the language with the given ID. An unknown or
+// empty ID is detected from the content instead. If highlighting fails, the
+// content is returned as escaped plain text, so that a snippet can always be
+// shown.
+func HTML(content string, language string) template.HTML {
+	if !Known(language) {
+		language = Detect(content)
+	}
+
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	// Normalise Windows line endings, which would otherwise show up as
+	// stray characters at the end of every line.
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return plain(content)
+	}
+
+	var buf bytes.Buffer
+
+	err = formatter.Format(&buf, style, iterator)
+	if err != nil {
+		return plain(content)
+	}
+
+	return template.HTML(buf.String())
+}
+
+func plain(content string) template.HTML {
+	return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+}
@@ -0,0 +1,71 @@
+// Package markdown renders Markdown snippets to HTML which is safe to embed
+// in a page.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// md converts GitHub Flavored Markdown (tables, task lists, strikethrough
+// and autolinks) to HTML. Raw HTML in the source is left out, because
+// goldmark isn't configured with html.WithUnsafe().
+var md = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// policy is the allowlist the HTML is sanitized with. It allows the
+// elements Markdown produces and nothing else: no scripts, no styles, no
+// event handlers, and links only with safe URL schemes. Links are marked
+// rel="nofollow noopener" and open in a new tab.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Task list items are rendered as disabled checkboxes.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// inputRX matches the <input> elements left after sanitizing. The policy
+// can't require attributes, so an <input checked> without a type would get
+// through as a text box. keepInput() drops everything but the disabled
+// checkboxes of task lists.
+var inputRX = regexp.MustCompile(`<input[^>]*>`)
+
+// keepInput reports whether a sanitized <input> element is a task list
+// checkbox. The sanitizer has already removed any other attributes and
+// quoted the values, so looking for these two is enough.
+func keepInput(tag []byte) bool {
+	return bytes.Contains(tag, []byte(` type="checkbox"`)) && bytes.Contains(tag, []byte(` disabled=""`))
+}
+
+// Render converts Markdown source to sanitized HTML. Even though goldmark
+// already drops raw HTML, the output goes through the sanitizer as well, so
+// that a bug or a future change in the Markdown renderer can't let unsafe
+// HTML through as template.HTML.
+func Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+
+	err := md.Convert([]byte(source), &buf)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(sanitize(buf.Bytes())), nil
+}
+
+// sanitize runs html through the policy and drops any inputs which aren't
+// task list checkboxes.
+func sanitize(html []byte) []byte {
+	return inputRX.ReplaceAllFunc(policy.SanitizeBytes(html), func(tag []byte) []byte {
+		if keepInput(tag) {
+			return tag
+		}
+		return nil
+	})
+}
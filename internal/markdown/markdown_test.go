@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "Task list",
+			source: "- [x] done\n- [ ] todo",
+			want: []string{
+				`<li><input checked="" disabled="" type="checkbox"> done</li>`,
+				`<li><input disabled="" type="checkbox"> todo</li>`,
+			},
+		},
+		{
+			name:   "Safe link",
+			source: "[docs](https://example.com)",
+			want:   []string{`<a href="https://example.com" rel="nofollow noopener" target="_blank">docs</a>`},
+		},
+		{
+			name:    "Script block",
+			source:  "<script>alert(1)</script>",
+			notWant: []string{"<script", "alert(1)"},
+		},
+		{
+			name:    "Raw HTML block",
+			source:  "<div onclick=\"alert(1)\">hi</div>",
+			notWant: []string{"<div", "onclick"},
+		},
+		{
+			name:    "Inline raw HTML",
+			source:  "text <span style=\"color:red\">red</span> <img src=x onerror=alert(1)>",
+			notWant: []string{"<span", "style", "<img", "onerror"},
+		},
+		{
+			name:    "javascript: link",
+			source:  "[click](javascript:alert(1))",
+			want:    []string{"<p>click</p>"},
+			notWant: []string{"href", "javascript:"},
+		},
+		{
+			name:    "data: link",
+			source:  "[click](data:text/html;base64,PHNjcmlwdD4=)",
+			notWant: []string{"href", "data:"},
+		},
+		{
+			name:    "javascript: image",
+			source:  "![pic](javascript:alert(1))",
+			notWant: []string{"src", "javascript:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := Render(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(string(html), want) {
+					t.Errorf("want output to contain %q; got %q", want, html)
+				}
+			}
+
+			for _, notWant := range tt.notWant {
+				if strings.Contains(string(html), notWant) {
+					t.Errorf("want output not to contain %q; got %q", notWant, html)
+				}
+			}
+		})
+	}
+}
+
+// TestSanitize checks the sanitizer on its own, with the raw HTML which
+// goldmark drops before it gets that far, in case goldmark ever lets some
+// through.
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"Script", `<p>hi</p><script>alert(1)</script>`, `<p>hi</p>`},
+		{"Event handler", `<p onclick="alert(1)" onmouseover="alert(2)">hi</p>`, `<p>hi</p>`},
+		{"Style attribute", `<p style="position:fixed">hi</p>`, `<p>hi</p>`},
+		{"Style element", `<style>body{display:none}</style><p>hi</p>`, `<p>hi</p>`},
+		{"javascript: link", `<a href="javascript:alert(1)">x</a>`, `x`},
+		{"data: link", `<a href="data:text/html,<script>alert(1)</script>">x</a>`, `x`},
+		{"Image handler", `<img src="https://example.com/a.png" onerror="alert(1)">`, `<img src="https://example.com/a.png">`},
+		{"Iframe", `<iframe src="https://example.com"></iframe>`, ``},
+		{"Form", `<form action="/user/logout" method="post"><button>x</button></form>`, `x`},
+		{"Task checkbox", `<input checked="" disabled="" type="checkbox">`, `<input checked="" disabled="" type="checkbox">`},
+		{"Checkbox extras", `<input type="checkbox" disabled name="a" value="b" onclick="alert(1)" style="x" formaction="javascript:alert(1)">`, `<input type="checkbox" disabled="">`},
+		{"Enabled checkbox", `<input type="checkbox">`, ``},
+		{"Text input", `<input type="text" disabled>`, ``},
+		{"Hidden input", `<input type="hidden" name="csrf_token" value="x" disabled>`, ``},
+		{"Input without type", `<input checked disabled>`, ``},
+		{"Type with space", `<input type="checkbox " disabled>`, ``},
+		{"Quote in value", `<input checked="a>b" disabled="" type="checkbox">`, `<input checked="a&gt;b" disabled="" type="checkbox">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(sanitize([]byte(tt.html))); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The migration files are embedded into the binary, one directory per SQL
+// dialect. Files are named like 0001_create_snippets_table.up.sql and
+// 0001_create_snippets_table.down.sql.
+//
+//go:embed mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+var (
+	ErrUnknownDialect = errors.New("migrations: unknown dialect")
+	ErrUnknownVersion = errors.New("migrations: unknown version")
+	ErrNoChange       = errors.New("migrations: no change")
+)
+
+// Migration holds the SQL for a single version of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied, and when.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts migrations for one database, keeping track of
+// which versions have been applied in the schema_migrations table.
+type Migrator struct {
+	DB         *sql.DB
+	migrations []Migration
+}
+
+// New loads the embedded migrations for the given dialect ("mysql" or
+// "sqlite") and returns a Migrator for db.
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := load(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{DB: db, migrations: migrations}, nil
+}
+
+// load reads and pairs up the .up.sql and .down.sql files for a dialect,
+// returning them sorted by version.
+func load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDialect, dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		prefix, rest, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrations: malformed file name %q", name)
+		}
+
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrations: malformed version in %q", name)
+		}
+
+		body, err := fs.ReadFile(files, path.Join(dialect, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{
+				Version: version,
+				Name:    strings.TrimSuffix(rest, "."+direction+".sql"),
+			}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	var migrations []Migration
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Latest returns the highest version known to the Migrator.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// ensureTable creates the schema_migrations table if it doesn't exist yet.
+// The statement is plain enough to work on both MySQL and SQLite.
+func (m *Migrator) ensureTable() error {
+	stmt := `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	_, err := m.DB.Exec(stmt)
+	return err
+}
+
+// applied returns the time each applied version was recorded at.
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	err := m.ensureTable()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// Version returns the highest applied version, or 0 if nothing has been
+// applied yet.
+func (m *Migrator) Version() (int, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+
+	return version, nil
+}
+
+// Status reports every known migration along with whether it has been
+// applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, mig := range m.migrations {
+		appliedAt, ok := applied[mig.Version]
+		statuses = append(statuses, Status{
+			Migration: mig,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+// Up applies every pending migration and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	return m.To(m.Latest())
+}
+
+// Down reverts the most recently applied migration.
+func (m *Migrator) Down() ([]Migration, error) {
+	version, err := m.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 0 {
+		return nil, ErrNoChange
+	}
+
+	// Find the version just below the current one, so we only revert a
+	// single step.
+	target := 0
+	for _, mig := range m.migrations {
+		if mig.Version < version {
+			target = mig.Version
+		}
+	}
+
+	return m.To(target)
+}
+
+// To migrates up or down until the schema is at the given version. Version
+// 0 reverts every migration. It returns the migrations it applied or
+// reverted, in the order they were run.
+func (m *Migrator) To(version int) ([]Migration, error) {
+	if version != 0 && !m.known(version) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+
+	// Apply anything at or below the target that hasn't been applied yet,
+	// oldest first.
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+
+		err = m.run(mig.Up, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, mig.Version, mig.Name)
+		if err != nil {
+			return done, fmt.Errorf("migrations: applying %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+
+	// Revert anything above the target that has been applied, newest first.
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mig := m.migrations[i]
+		if mig.Version <= version {
+			break
+		}
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+
+		err = m.run(mig.Down, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+		if err != nil {
+			return done, fmt.Errorf("migrations: reverting %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+
+	return done, nil
+}
+
+func (m *Migrator) known(version int) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// run executes the statements of one migration and the bookkeeping statement
+// in a single transaction. Note that MySQL implicitly commits DDL statements,
+// so a failed migration may be left partially applied.
+func (m *Migrator) run(body string, record string, args ...any) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	for _, stmt := range split(body) {
+		_, err = tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(record, args...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// split breaks a migration file into individual statements. The MySQL driver
+// won't run more than one statement per Exec() call unless multiStatements is
+// enabled in the DSN, so we run them one at a time instead. Statements are
+// expected to end with a semicolon at the end of a line, which is stripped.
+func split(body string) []string {
+	var (
+		stmts   []string
+		current strings.Builder
+	)
+
+	for line := range strings.Lines(body) {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSpace(current.String())
+			stmts = append(stmts, strings.TrimSuffix(stmt, ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+
+	return stmts
+}
@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_expires ON snippets;
+
+DROP TABLE snippets_archive;
@@ -0,0 +1,9 @@
+DROP TABLE snippet_revisions;
+
+ALTER TABLE snippets DROP FOREIGN KEY fk_snippets_user;
+
+ALTER TABLE snippets DROP COLUMN updated;
+
+ALTER TABLE snippets DROP COLUMN revision;
+
+ALTER TABLE snippets DROP COLUMN user_id;
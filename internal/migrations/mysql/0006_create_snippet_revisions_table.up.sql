@@ -0,0 +1,21 @@
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+ALTER TABLE snippets ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE snippets ADD COLUMN updated DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE snippets SET updated = created;
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE TABLE snippet_revisions (
+    snippet_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, revision),
+    CONSTRAINT fk_snippet_revisions_snippet
+        FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN password_hash CHAR(60) NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- Existing snippets get 12-character hex slugs, which are also valid base62.
+-- New snippets get 10-character base62 slugs from Insert(), so the length
+-- tells the two apart, and only the old ones still redirect from their
+-- numeric URLs. The binary collation keeps slugs case-sensitive.
+ALTER TABLE snippets ADD COLUMN slug VARCHAR(16) CHARACTER SET ascii COLLATE ascii_bin NULL;
+UPDATE snippets SET slug = LOWER(HEX(RANDOM_BYTES(6)));
+ALTER TABLE snippets MODIFY slug VARCHAR(16) CHARACTER SET ascii COLLATE ascii_bin NOT NULL;
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets(slug);
@@ -0,0 +1,11 @@
+-- Token buckets for the rate limiter, when it's told to share them between
+-- instances through the database. The times are Unix microseconds, as
+-- buckets refill in fractions of a second.
+CREATE TABLE rate_limits (
+    bucket VARCHAR(255) CHARACTER SET ascii COLLATE ascii_bin NOT NULL PRIMARY KEY,
+    tokens DOUBLE NOT NULL,
+    updated BIGINT NOT NULL,
+    full_at BIGINT NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_full_at ON rate_limits(full_at);
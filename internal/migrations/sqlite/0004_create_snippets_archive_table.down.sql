@@ -0,0 +1,3 @@
+DROP INDEX idx_snippets_expires;
+
+DROP TABLE snippets_archive;
@@ -0,0 +1,7 @@
+DROP TABLE snippet_revisions;
+
+ALTER TABLE snippets DROP COLUMN updated;
+
+ALTER TABLE snippets DROP COLUMN revision;
+
+ALTER TABLE snippets DROP COLUMN user_id;
@@ -0,0 +1,18 @@
+-- SQLite can't drop a column which is part of a foreign key, so user_id is
+-- left without one to keep this migration reversible.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+ALTER TABLE snippets ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE snippets ADD COLUMN updated DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE snippets SET updated = created;
+
+CREATE TABLE snippet_revisions (
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (snippet_id, revision)
+);
@@ -0,0 +1,3 @@
+-- SQLite has no FULLTEXT indexes, so Search() falls back to LIKE there.
+-- This migration is empty, and only keeps the version numbers of the two
+-- dialects in step.
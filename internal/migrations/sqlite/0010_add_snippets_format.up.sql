@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN format TEXT NOT NULL DEFAULT 'code';
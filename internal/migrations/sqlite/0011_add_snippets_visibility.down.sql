@@ -0,0 +1,2 @@
+ALTER TABLE snippets DROP COLUMN password_hash;
+ALTER TABLE snippets DROP COLUMN visibility;
@@ -0,0 +1,2 @@
+ALTER TABLE snippets ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+ALTER TABLE snippets ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
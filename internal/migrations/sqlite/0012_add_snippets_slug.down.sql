@@ -0,0 +1,2 @@
+DROP INDEX idx_snippets_slug;
+ALTER TABLE snippets DROP COLUMN slug;
@@ -0,0 +1,8 @@
+-- Existing snippets get 12-character hex slugs, which are also valid base62.
+-- New snippets get 10-character base62 slugs from Insert(), so the length
+-- tells the two apart, and only the old ones still redirect from their
+-- numeric URLs. SQLite can't add a NOT NULL column without a default, but
+-- Insert() always sets the slug.
+ALTER TABLE snippets ADD COLUMN slug TEXT;
+UPDATE snippets SET slug = lower(hex(randomblob(6)));
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets(slug);
@@ -0,0 +1,11 @@
+-- Token buckets for the rate limiter, when it's told to share them between
+-- instances through the database. The times are Unix microseconds, as
+-- buckets refill in fractions of a second.
+CREATE TABLE rate_limits (
+    bucket TEXT NOT NULL PRIMARY KEY,
+    tokens REAL NOT NULL,
+    updated INTEGER NOT NULL,
+    full_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_rate_limits_full_at ON rate_limits(full_at);
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The orders List() can sort snippets in: newest first, soonest to expire
+// first, or alphabetically by title. Ties are broken by slug, so that every
+// snippet has a unique position in the listing.
+const (
+	SortCreated = "created"
+	SortExpires = "expires"
+	SortTitle   = "title"
+)
+
+// SortOrders lists the valid values for ListOptions.Sort.
+var SortOrders = []string{SortCreated, SortExpires, SortTitle}
+
+// sortDesc records which sort orders run from the largest value down.
+var sortDesc = map[string]bool{SortCreated: true}
+
+// ListOptions selects one page of a snippet listing. After and Before are
+// cursors taken from a previous Page's Next or Prev field. At most one of
+// them may be set; if neither is, List() returns the first page. If Tag is
+// set, only snippets with that tag are listed.
+type ListOptions struct {
+	Sort   string
+	After  string
+	Before string
+	Limit  int
+	Tag    string
+}
+
+// Page is one page of a snippet listing. Next and Prev are the cursors for
+// the following and preceding pages, or empty if there are none.
+type Page struct {
+	Snippets []Snippet
+	Next     string
+	Prev     string
+}
+
+// encodeCursor returns an opaque cursor for the position of s in a listing
+// sorted by sortBy. It records the value of the sort column and the slug,
+// which is all a keyset query needs to carry on from there. It's the slug
+// rather than the ID, as cursors are only base64 encoded and the IDs would
+// give away how many snippets there are.
+func encodeCursor(sortBy string, s Snippet) string {
+	var value string
+
+	switch sortBy {
+	case SortCreated:
+		value = s.Created.UTC().Format(time.RFC3339)
+	case SortExpires:
+		value = s.Expires.UTC().Format(time.RFC3339)
+	case SortTitle:
+		value = s.Title
+	}
+
+	raw := sortBy + "," + s.Slug + "," + value
+
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor reverses encodeCursor(), returning a Snippet with only the
+// slug and the sort column filled in. Cursors from a listing with a different
+// sort order are rejected.
+func decodeCursor(sortBy string, cursor string) (Snippet, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Snippet{}, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ",", 3)
+	if len(parts) != 3 || parts[0] != sortBy || !ValidSlug(parts[1]) {
+		return Snippet{}, ErrInvalidCursor
+	}
+
+	s := Snippet{Slug: parts[1]}
+
+	switch sortBy {
+	case SortCreated:
+		s.Created, err = time.Parse(time.RFC3339, parts[2])
+	case SortExpires:
+		s.Expires, err = time.Parse(time.RFC3339, parts[2])
+	case SortTitle:
+		s.Title = parts[2]
+	}
+	if err != nil {
+		return Snippet{}, ErrInvalidCursor
+	}
+
+	return s, nil
+}
+
+// compareSnippets orders a and b by the sort column and then by slug, both
+// ascending. Slugs are compared byte by byte, like the binary collation of
+// the slug column.
+func compareSnippets(sortBy string, a, b Snippet) int {
+	var c int
+
+	switch sortBy {
+	case SortCreated:
+		c = a.Created.Compare(b.Created)
+	case SortExpires:
+		c = a.Expires.Compare(b.Expires)
+	case SortTitle:
+		c = strings.Compare(a.Title, b.Title)
+	}
+
+	if c == 0 {
+		c = strings.Compare(a.Slug, b.Slug)
+	}
+
+	return c
+}
+
+// checkListOptions validates opts and decodes its cursor, if it has one.
+// backward reports whether we're paging towards the start of the listing.
+func checkListOptions(opts ListOptions) (from *Snippet, backward bool, err error) {
+	if !slices.Contains(SortOrders, opts.Sort) {
+		return nil, false, fmt.Errorf("models: unknown sort order %q", opts.Sort)
+	}
+
+	if opts.Limit < 1 {
+		return nil, false, fmt.Errorf("models: invalid page limit %d", opts.Limit)
+	}
+
+	if opts.After != "" && opts.Before != "" {
+		return nil, false, ErrInvalidCursor
+	}
+
+	cursor, backward := opts.After, false
+	if opts.Before != "" {
+		cursor, backward = opts.Before, true
+	}
+
+	if cursor == "" {
+		return nil, false, nil
+	}
+
+	s, err := decodeCursor(opts.Sort, cursor)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &s, backward, nil
+}
+
+// newPage builds a Page from up to opts.Limit+1 snippets fetched in the
+// direction we're paging in. The extra snippet only tells us whether
+// there's another page beyond this one.
+func newPage(opts ListOptions, snippets []Snippet, backward bool) Page {
+	more := len(snippets) > opts.Limit
+	if more {
+		snippets = snippets[:opts.Limit]
+	}
+
+	// Paging backwards fetches the snippets nearest the cursor first, so
+	// put them back in listing order.
+	if backward {
+		slices.Reverse(snippets)
+	}
+
+	page := Page{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page
+	}
+
+	first, last := snippets[0], snippets[len(snippets)-1]
+
+	// We can always go back the way we came. Going further needs the extra
+	// snippet to have turned up.
+	if backward {
+		page.Next = encodeCursor(opts.Sort, last)
+		if more {
+			page.Prev = encodeCursor(opts.Sort, first)
+		}
+	} else {
+		if more {
+			page.Next = encodeCursor(opts.Sort, last)
+		}
+		if opts.After != "" {
+			page.Prev = encodeCursor(opts.Sort, first)
+		}
+	}
+
+	return page
+}
+
+// listSnippets runs the keyset query for List(), shared by the MySQL and
+// SQLite models. Rather than an OFFSET, which has to skip over every earlier
+// row, it seeks straight to the rows on the far side of the cursor, so later
+// pages are as cheap as the first.
+func listSnippets(db *sql.DB, d dialect, opts ListOptions) (Page, error) {
+	from, backward, err := checkListOptions(opts)
+	if err != nil {
+		return Page{}, err
+	}
+
+	column := opts.Sort
+
+	// Work out which way the rows run in the direction we're paging.
+	desc := sortDesc[opts.Sort] != backward
+
+	op, order := ">", "ASC"
+	if desc {
+		op, order = "<", "DESC"
+	}
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > ` + d.now + ` AND ` + listed
+
+	var args []any
+
+	if opts.Tag != "" {
+		stmt += ` AND id IN (SELECT st.snippet_id FROM snippet_tags st
+		INNER JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+		args = append(args, opts.Tag)
+	}
+
+	if from != nil {
+		var value any
+
+		switch opts.Sort {
+		case SortCreated:
+			value = d.time(from.Created)
+		case SortExpires:
+			value = d.time(from.Expires)
+		case SortTitle:
+			value = from.Title
+		}
+
+		stmt += fmt.Sprintf(" AND (%[1]s %[2]s ? OR (%[1]s = ? AND slug %[2]s ?))", column, op)
+		args = append(args, value, value, from.Slug)
+	}
+
+	stmt += fmt.Sprintf(" ORDER BY %[1]s %[2]s, slug %[2]s LIMIT ?", column, order)
+	args = append(args, opts.Limit+1)
+
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return Page{}, err
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return Page{}, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return Page{}, err
+	}
+
+	err = loadTags(db, snippets)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return newPage(opts, snippets, backward), nil
+}
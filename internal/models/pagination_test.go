@@ -0,0 +1,112 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	s := Snippet{
+		ID:      42,
+		Slug:    "aZ3kQ9xP0w",
+		Title:   "Commas, too",
+		Created: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+		Expires: time.Date(2024, 3, 8, 9, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		sortBy string
+		check  func(got Snippet) bool
+	}{
+		{SortCreated, func(got Snippet) bool { return got.Created.Equal(s.Created) }},
+		{SortExpires, func(got Snippet) bool { return got.Expires.Equal(s.Expires) }},
+		{SortTitle, func(got Snippet) bool { return got.Title == s.Title }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy, func(t *testing.T) {
+			cursor := encodeCursor(tt.sortBy, s)
+
+			got, err := decodeCursor(tt.sortBy, cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Slug != s.Slug {
+				t.Errorf("got slug %q; want %q", got.Slug, s.Slug)
+			}
+			if !tt.check(got) {
+				t.Errorf("sort value didn't survive the round trip: got %+v", got)
+			}
+		})
+	}
+}
+
+func TestCursorHidesID(t *testing.T) {
+	s := Snippet{ID: 987654, Slug: "aZ3kQ9xP0w", Title: "Title"}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encodeCursor(SortTitle, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(raw), "987654") {
+		t.Errorf("cursor %q contains the snippet ID", raw)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		sortBy string
+		cursor string
+	}{
+		{"Not base64", SortCreated, "!!!"},
+		{"Too few parts", SortCreated, encode("created,aZ3kQ9xP0w")},
+		{"Other sort order", SortTitle, encode("created,aZ3kQ9xP0w,2024-03-01T09:30:00Z")},
+		{"Numeric ID", SortTitle, encode("title,42,Title")},
+		{"Bad slug", SortTitle, encode("title,not-a-slug,Title")},
+		{"Bad time", SortCreated, encode("created,aZ3kQ9xP0w,yesterday")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeCursor(tt.sortBy, tt.cursor)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("got error %v; want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCompareSnippets(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		sortBy string
+		a, b   Snippet
+		want   int
+	}{
+		{"Earlier first", SortCreated, Snippet{Created: day, Slug: "bbbbbbbbbb"}, Snippet{Created: day.Add(time.Second), Slug: "aaaaaaaaaa"}, -1},
+		{"Tie broken by slug", SortCreated, Snippet{Created: day, Slug: "aaaaaaaaaa"}, Snippet{Created: day, Slug: "bbbbbbbbbb"}, -1},
+		{"Slugs compare bytewise", SortTitle, Snippet{Title: "t", Slug: "Zzzzzzzzzz"}, Snippet{Title: "t", Slug: "aaaaaaaaaa"}, -1},
+		{"Equal", SortTitle, Snippet{Title: "t", Slug: "aaaaaaaaaa"}, Snippet{Title: "t", Slug: "aaaaaaaaaa"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareSnippets(tt.sortBy, tt.a, tt.b)
+			if got != tt.want || compareSnippets(tt.sortBy, tt.b, tt.a) != -tt.want {
+				t.Errorf("got %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/fatonh/lovrinbox/internal/ratelimit"
+)
+
+// RateLimitModel keeps the rate limiter's token buckets in the rate_limits
+// table of a MySQL database, so that every instance of the application
+// sharing the database also shares the limits. SQLiteRateLimitModel does
+// the same for SQLite. Both satisfy ratelimit.Store.
+type RateLimitModel struct {
+	DB *sql.DB
+}
+
+// SQLiteRateLimitModel is the SQLite flavour of RateLimitModel.
+type SQLiteRateLimitModel struct {
+	DB *sql.DB
+}
+
+// Take takes a token from the bucket for key.
+func (m *RateLimitModel) Take(key string, l ratelimit.Limit, now time.Time) (bool, time.Duration, error) {
+	return takeToken(m.DB, mysqlDialect, key, l, now)
+}
+
+// Evict deletes the buckets which are full again at now.
+func (m *RateLimitModel) Evict(now time.Time) (int, error) {
+	return evictBuckets(m.DB, now)
+}
+
+// Take takes a token from the bucket for key.
+func (m *SQLiteRateLimitModel) Take(key string, l ratelimit.Limit, now time.Time) (bool, time.Duration, error) {
+	return takeToken(m.DB, sqliteDialect, key, l, now)
+}
+
+// Evict deletes the buckets which are full again at now.
+func (m *SQLiteRateLimitModel) Evict(now time.Time) (int, error) {
+	return evictBuckets(m.DB, now)
+}
+
+// takeToken reads, updates and writes back a bucket in one transaction.
+// The bucket is inserted full first if it doesn't exist yet, so that there's
+// always a row for SELECT ... FOR UPDATE to lock, and two instances can't
+// both take the last token.
+func takeToken(db *sql.DB, d dialect, key string, l ratelimit.Limit, now time.Time) (bool, time.Duration, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, 0, err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	stmt := d.insertIgnore + ` INTO rate_limits (bucket, tokens, updated, full_at)
+	VALUES (?, ?, ?, ?)`
+
+	_, err = tx.Exec(stmt, key, l.Burst, now.UnixMicro(), now.UnixMicro())
+	if err != nil {
+		return false, 0, err
+	}
+
+	var (
+		b       ratelimit.Bucket
+		updated int64
+	)
+
+	stmt = `SELECT tokens, updated FROM rate_limits WHERE bucket = ?` + d.forUpdate
+
+	err = tx.QueryRow(stmt, key).Scan(&b.Tokens, &updated)
+	if err != nil {
+		return false, 0, err
+	}
+	b.Updated = time.UnixMicro(updated)
+
+	allowed, retryAfter := b.Take(l, now)
+
+	stmt = `UPDATE rate_limits SET tokens = ?, updated = ?, full_at = ? WHERE bucket = ?`
+
+	_, err = tx.Exec(stmt, b.Tokens, b.Updated.UnixMicro(), b.FullAt(l).UnixMicro(), key)
+	if err != nil {
+		return false, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return allowed, retryAfter, nil
+}
+
+// evictBuckets deletes the buckets which are full again at now.
+func evictBuckets(db *sql.DB, now time.Time) (int, error) {
+	result, err := db.Exec(`DELETE FROM rate_limits WHERE full_at <= ?`, now.UnixMicro())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
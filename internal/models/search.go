@@ -0,0 +1,150 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"unicode/utf8"
+)
+
+// SearchPageSize is the number of results on each page returned by
+// Search().
+const SearchPageSize = 20
+
+// maxSearchTerms caps how many words of a query are searched for, so that a
+// huge query can't turn into a huge SQL statement.
+const maxSearchTerms = 10
+
+// ftMinTokenSize is InnoDB's default innodb_ft_min_token_size. Shorter
+// words aren't in a FULLTEXT index.
+const ftMinTokenSize = 3
+
+// ftStopwords is InnoDB's default list of stopwords, which aren't in a
+// FULLTEXT index either.
+var ftStopwords = map[string]bool{
+	"a": true, "about": true, "an": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "com": true, "de": true, "en": true, "for": true,
+	"from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"la": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "what": true, "when": true,
+	"where": true, "who": true, "will": true, "with": true, "und": true,
+	"www": true,
+}
+
+// fullTextIndexed reports whether MySQL can find word through the FULLTEXT
+// index, i.e. it's long enough and not a stopword.
+func fullTextIndexed(word string) bool {
+	return utf8.RuneCountInString(word) >= ftMinTokenSize && !ftStopwords[strings.ToLower(word)]
+}
+
+// SearchResults is one page of search results. Page starts at 1.
+type SearchResults struct {
+	Snippets []Snippet
+	Page     int
+	HasNext  bool
+}
+
+// SearchTerms splits a search query into the words Search() looks for.
+// Handlers use it too, to highlight the same words in the results.
+func SearchTerms(query string) []string {
+	terms := strings.Fields(query)
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+
+	return terms
+}
+
+// likeEscape is the escape character for LIKE patterns. It isn't a
+// backslash, because MySQL treats a backslash in a string literal as an
+// escape too (unless NO_BACKSLASH_ESCAPES is set), so ESCAPE '\' would be
+// a syntax error there, while ESCAPE '\\' isn't a single character in SQLite.
+const likeEscape = "!"
+
+// escapeLike escapes the LIKE wildcards in s, so that a search for "50%"
+// only matches a literal percent sign. It's used with ESCAPE '!'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(likeEscape, likeEscape+likeEscape,
+		`%`, likeEscape+`%`, `_`, likeEscape+`_`).Replace(s)
+}
+
+// searchSnippets runs a search query built by one of the models. The
+// statement must end with LIMIT ? OFFSET ?. One extra row is fetched to find
+// out whether there's another page.
+func searchSnippets(db *sql.DB, stmt string, args []any, page int) (SearchResults, error) {
+	args = append(args, SearchPageSize+1, (page-1)*SearchPageSize)
+
+	rows, err := db.Query(stmt, args...)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	defer rows.Close()
+
+	results := SearchResults{Page: page}
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return SearchResults{}, err
+		}
+
+		results.Snippets = append(results.Snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return SearchResults{}, err
+	}
+
+	if len(results.Snippets) > SearchPageSize {
+		results.Snippets = results.Snippets[:SearchPageSize]
+		results.HasNext = true
+	}
+
+	err = loadTags(db, results.Snippets)
+	if err != nil {
+		return SearchResults{}, err
+	}
+
+	return results, nil
+}
+
+// likeSearch is the portable version of Search(), for databases without a
+// full-text index. Every term must appear in the title or the content, and
+// the newest snippets come first.
+func likeSearch(db *sql.DB, d dialect, query string, page int) (SearchResults, error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 || page < 1 {
+		return SearchResults{Page: page}, nil
+	}
+
+	stmt, args := likeSearchStmt(d, terms)
+
+	return searchSnippets(db, stmt, args, page)
+}
+
+// likeSearchStmt builds the statement run by likeSearch().
+func likeSearchStmt(d dialect, terms []string) (string, []any) {
+	conditions, args := likeConditions(terms)
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > ` + d.now + ` AND ` + listed + conditions + `
+	ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	return stmt, args
+}
+
+// likeConditions returns the WHERE conditions, each starting with AND,
+// which require every term to appear in the title or the content.
+func likeConditions(terms []string) (string, []any) {
+	var (
+		conditions string
+		args       []any
+	)
+
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		conditions += ` AND (title LIKE ? ESCAPE '` + likeEscape + `' OR content LIKE ? ESCAPE '` + likeEscape + `')`
+		args = append(args, pattern, pattern)
+	}
+
+	return conditions, args
+}
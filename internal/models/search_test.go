@@ -0,0 +1,110 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"50%", "50!%"},
+		{"snake_case", "snake!_case"},
+		{"wow!", "wow!!"},
+		{`C:\temp`, `C:\temp`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := escapeLike(tt.in); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// checkLikeClauses checks that stmt escapes its LIKE patterns with a
+// character which means the same thing in MySQL and SQLite string literals.
+func checkLikeClauses(t *testing.T, stmt string, terms int) {
+	t.Helper()
+
+	if strings.Contains(stmt, `\`) {
+		t.Errorf("statement contains a backslash, which MySQL reads as an escape:\n%s", stmt)
+	}
+
+	if got, want := strings.Count(stmt, `LIKE ? ESCAPE '!'`), 2*terms; got != want {
+		t.Errorf("got %d escaped LIKE clauses; want %d:\n%s", got, want, stmt)
+	}
+}
+
+func TestLikeSearchStmt(t *testing.T) {
+	dialects := []struct {
+		name string
+		d    dialect
+	}{
+		{"MySQL", mysqlDialect},
+		{"SQLite", sqliteDialect},
+	}
+
+	terms := []string{"go", "50%"}
+
+	for _, tt := range dialects {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, args := likeSearchStmt(tt.d, terms)
+
+			checkLikeClauses(t, stmt, len(terms))
+
+			if !strings.Contains(stmt, "expires > "+tt.d.now) {
+				t.Errorf("statement doesn't use the dialect's current time:\n%s", stmt)
+			}
+
+			want := []any{"%go%", "%go%", "%50!%%", "%50!%%"}
+			if !slices.Equal(args, want) {
+				t.Errorf("got args %q; want %q", args, want)
+			}
+		})
+	}
+}
+
+func TestFullTextSearchStmt(t *testing.T) {
+	tests := []struct {
+		name        string
+		terms       []string
+		wantAgainst string
+		wantLike    []string
+	}{
+		{"Only indexed words", []string{"gopher", "conc"}, "+gopher* +conc*", nil},
+		{"Short words and stopwords use LIKE", []string{"go", "the", "channels"}, "+channels*", []string{"%go%", "%the%"}},
+		{"Operators are dropped", []string{"+error-handling", "c++"}, "+errorhandling*", []string{"%c++%"}},
+		{"Nothing indexed", []string{"go", "is"}, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt, args := fullTextSearchStmt(tt.terms)
+
+			if tt.wantAgainst == "" {
+				if stmt != "" {
+					t.Errorf("got a statement; want none:\n%s", stmt)
+				}
+				return
+			}
+
+			checkLikeClauses(t, stmt, len(tt.wantLike))
+
+			want := []any{tt.wantAgainst}
+			for _, pattern := range tt.wantLike {
+				want = append(want, pattern, pattern)
+			}
+			want = append(want, tt.wantAgainst)
+
+			if !slices.Equal(args, want) {
+				t.Errorf("got args %q; want %q", args, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+)
+
+// slugAlphabet is the base62 alphabet slugs are made from. It's safe in
+// URLs without any escaping.
+const slugAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// slugLength is the length of the slugs made by Insert(). There are 62^10,
+// or about 8×10^17, of them, so they can't be guessed or counted.
+const slugLength = 10
+
+// legacySlugLength is the length of the hex slugs migration 0012 gave the
+// snippets which already existed.
+const legacySlugLength = 12
+
+// maxSlugAttempts is how many slugs Insert() tries before giving up. A
+// collision is so unlikely that needing more than one is already a
+// surprise.
+const maxSlugAttempts = 5
+
+// errSlugCollision is returned by Insert() if every slug it tried was
+// taken.
+var errSlugCollision = errors.New("models: couldn't generate a unique slug")
+
+// newSlug returns a random base62 slug. Random bytes of 248 or more are
+// skipped, because 248 is the largest multiple of 62 which fits in a byte,
+// and using them would make some characters more likely than others.
+func newSlug() (string, error) {
+	slug := make([]byte, 0, slugLength)
+	buf := make([]byte, slugLength*2)
+
+	for len(slug) < slugLength {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return "", err
+		}
+
+		for _, b := range buf {
+			if b >= 248 {
+				continue
+			}
+			slug = append(slug, slugAlphabet[b%62])
+			if len(slug) == slugLength {
+				break
+			}
+		}
+	}
+
+	return string(slug), nil
+}
+
+// ValidSlug reports whether s looks like a slug, so that handlers can
+// turn other values away without querying the database.
+func ValidSlug(s string) bool {
+	if len(s) != slugLength && len(s) != legacySlugLength {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HasNumericURL reports whether the snippet can still be found by its
+// numeric ID. Only snippets created before slugs were added can, so that
+// links to them keep working; newer snippets are only reachable through
+// their slug.
+func (s Snippet) HasNumericURL() bool {
+	return len(s.Slug) == legacySlugLength
+}
+
+// uniqueSlug generates slugs until it finds one which isn't in use. It runs
+// inside the transaction which inserts the snippet, and the unique index on
+// the slug column catches the (astronomically unlikely) case of another
+// transaction picking the same slug at the same time.
+func uniqueSlug(tx *sql.Tx) (string, error) {
+	for range maxSlugAttempts {
+		slug, err := newSlug()
+		if err != nil {
+			return "", err
+		}
+
+		var exists bool
+		err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM snippets WHERE slug = ?)`, slug).Scan(&exists)
+		if err != nil {
+			return "", err
+		}
+
+		if !exists {
+			return slug, nil
+		}
+	}
+
+	return "", errSlugCollision
+}
+
+// getSnippetBySlug is GetBySlug() for the MySQL and SQLite models.
+func getSnippetBySlug(db *sql.DB, d dialect, slug string) (Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > ` + d.now + ` AND slug = ?`
+
+	s, err := scanSnippet(db.QueryRow(stmt, slug))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	snippets := []Snippet{s}
+	err = loadTags(db, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
@@ -3,55 +3,402 @@ package models
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
 // deffine a Snippet struct to hold data for an individual snippet
 // Notice how the fields of the struct corepond to the feilds in our
 // Mysql snippets table
+// The struct tags control how the snippet is encoded by the JSON API.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	// ID is only used internally. Sequential IDs would let anyone count
+	// or enumerate our snippets, so URLs and the JSON API use the random
+	// Slug instead.
+	ID      int       `json:"-"`
+	Slug    string    `json:"slug"`
+	Title   string    `json:"title"`
+	Content string    `json:"content"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	// BurnAfterReading snippets are deleted the first time they're viewed.
+	BurnAfterReading bool `json:"burn_after_reading"`
+	// UserID is the owner of the snippet, or 0 if it has none.
+	UserID int `json:"-"`
+	// Revision starts at 1 and goes up every time the snippet is edited.
+	// Updated is when the current revision was saved.
+	Revision int       `json:"revision"`
+	Updated  time.Time `json:"updated"`
+	// Tags are sorted by name.
+	Tags []string `json:"tags"`
+	// Language is the ID of the language the content is highlighted as.
+	// Snippets created before languages were added have an empty one.
+	Language string `json:"language"`
+	// Format says how the content is shown: FormatPlain, FormatCode or
+	// FormatMarkdown.
+	Format string `json:"format"`
+	// Visibility says who can see the snippet. See the Visibility
+	// constants.
+	Visibility string `json:"visibility"`
+	// PasswordHash is the bcrypt hash of the password which unlocks a
+	// VisibilityPassword snippet, and empty otherwise.
+	PasswordHash []byte `json:"-"`
+}
+
+// The visibilities a snippet can have. Public snippets are listed on the
+// home page and in searches. Unlisted snippets can be seen by anyone with
+// the link, but are never listed. Private snippets can only be seen by
+// their owner, and password snippets by anyone who knows the password.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+	VisibilityPassword = "password"
+)
+
+// Visibilities lists the valid values for Snippet.Visibility.
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate, VisibilityPassword}
+
+// VisibleTo reports whether the user with the given ID (0 for anonymous
+// visitors) may see the snippet at all. Password snippets are visible to
+// everyone, but their content must be unlocked with CheckPassword() first,
+// unless the user owns them.
+func (s Snippet) VisibleTo(userID int) bool {
+	if s.Visibility == VisibilityPrivate {
+		return userID != 0 && userID == s.UserID
+	}
+	return true
+}
+
+// Locked reports whether the user with the given ID needs the password to
+// see the content of the snippet.
+func (s Snippet) Locked(userID int) bool {
+	return s.Visibility == VisibilityPassword && (userID == 0 || userID != s.UserID)
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password unlocks the
+// snippet.
+func (s Snippet) CheckPassword(password string) error {
+	if s.Visibility != VisibilityPassword || len(s.PasswordHash) == 0 {
+		return ErrInvalidCredentials
+	}
+	return checkPassword(s.PasswordHash, password)
+}
+
+// The formats a snippet's content can be shown in. Plain text is shown as
+// it is, code is syntax highlighted and Markdown is rendered to HTML.
+const (
+	FormatPlain    = "plain"
+	FormatCode     = "code"
+	FormatMarkdown = "markdown"
+)
+
+// Formats lists the valid values for Snippet.Format.
+var Formats = []string{FormatPlain, FormatCode, FormatMarkdown}
+
+// NewSnippet holds the fields needed to insert a snippet. Expires is the
+// number of days until the snippet expires. Password is only used by
+// VisibilityPassword snippets, and is hashed by Insert().
+type NewSnippet struct {
+	UserID           int
+	Title            string
+	Content          string
+	Expires          int
+	BurnAfterReading bool
+	Tags             []string
+	Language         string
+	Format           string
+	Visibility       string
+	Password         string
+}
+
+// Revision is one version of a snippet's title and content.
+type Revision struct {
+	SnippetID int
+	Revision  int
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// SnippetStore describes the methods our handlers need from a snippet
+// backend. SnippetModel (MySQL), SQLiteSnippetModel and MemorySnippetModel
+// all satisfy it, so the application can switch between them at startup.
+type SnippetStore interface {
+	// Insert returns the slug of the new snippet.
+	Insert(snippet NewSnippet) (string, error)
+
+	// Get and GetBySlug return a snippet whatever its visibility, so that
+	// its owner can see and edit it. Handlers must check VisibleTo() and
+	// Locked() before showing it to anyone else.
+	Get(id int) (Snippet, error)
+	GetBySlug(slug string) (Snippet, error)
+
+	// Latest, List, Search and Tags only ever include public snippets.
+	Latest() ([]Snippet, error)
+
+	// List returns one page of unexpired snippets, leaving out
+	// burn-after-reading ones, using keyset pagination.
+	List(opts ListOptions) (Page, error)
+
+	// Search returns a page of unexpired snippets whose title or content
+	// match the query. Burn-after-reading snippets are never found.
+	Search(query string, page int) (SearchResults, error)
+
+	// Tags returns up to limit of the most used tags on listed snippets,
+	// most used first.
+	Tags(limit int) ([]TagCount, error)
+
+	// Update replaces the title and content of a snippet, keeping the
+	// previous version in the snippet_revisions table. Revisions returns
+	// every version of a snippet, oldest first, including the current one.
+	Update(id int, title string, content string) error
+	Revisions(id int) ([]Revision, error)
+
+	// Burn atomically returns and deletes a burn-after-reading snippet, so
+	// that only one reader ever sees it.
+	Burn(id int) (Snippet, error)
+
+	// DeleteExpired and ArchiveExpired remove up to limit snippets which
+	// expired at or before the given time, returning how many were removed.
+	// ArchiveExpired copies them to the snippets_archive table first.
+	DeleteExpired(before time.Time, limit int) (int, error)
+	ArchiveExpired(before time.Time, limit int) (int, error)
+}
+
+// snippetColumns lists the columns scanned by scanSnippet(), in order. It's
+// shared by the MySQL and SQLite models.
+const snippetColumns = "id, slug, title, content, created, expires, burn_after_reading, user_id, revision, updated, language, format, visibility, password_hash"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSnippet copies the snippetColumns of the current row into a Snippet.
+func scanSnippet(row rowScanner) (Snippet, error) {
+	var (
+		s      Snippet
+		userID sql.NullInt64
+	)
+
+	err := row.Scan(&s.ID, &s.Slug, &s.Title, &s.Content, &s.Created, &s.Expires,
+		&s.BurnAfterReading, &userID, &s.Revision, &s.Updated, &s.Language, &s.Format,
+		&s.Visibility, &s.PasswordHash)
+
+	s.UserID = int(userID.Int64)
+
+	return s, err
+}
+
+// nullInt maps 0 to NULL, for optional foreign keys like user_id.
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+// dialect holds the bits of SQL which differ between MySQL and SQLite, so
+// that the more involved queries can be shared by both models.
+type dialect struct {
+	// now is an expression for the current UTC time.
+	now string
+	// forUpdate is appended to SELECT statements which lock the rows
+	// they read inside a transaction. SQLite locks the whole database
+	// for writes instead, so it's empty there.
+	forUpdate string
+	// time converts a time into a query argument which compares correctly
+	// with DATETIME columns.
+	time func(t time.Time) any
+	// insertIgnore starts an INSERT which skips rows that would violate a
+	// unique constraint.
+	insertIgnore string
+}
+
+// listed is the condition for snippets which may appear in listings,
+// searches and the tag cloud: public ones which aren't burn-after-reading.
+const listed = "NOT burn_after_reading AND visibility = 'public'"
+
+// passwordHash hashes the password of a new VisibilityPassword snippet.
+// Other snippets get an empty hash.
+func passwordHash(snippet NewSnippet) ([]byte, error) {
+	if snippet.Visibility != VisibilityPassword {
+		return []byte{}, nil
+	}
+	return hashPassword(snippet.Password)
+}
+
+var (
+	mysqlDialect = dialect{
+		now:       "UTC_TIMESTAMP()",
+		forUpdate: " FOR UPDATE",
+		time:      func(t time.Time) any { return t.UTC() },
+
+		insertIgnore: "INSERT IGNORE",
+	}
+	sqliteDialect = dialect{
+		now:       "datetime('now')",
+		forUpdate: "",
+		time:      func(t time.Time) any { return sqliteTime(t) },
+
+		insertIgnore: "INSERT OR IGNORE",
+	}
+)
+
+// updateSnippet copies the current version of a snippet into
+// snippet_revisions and then overwrites it, in a single transaction.
+// Expired and burn-after-reading snippets can't be edited.
+func updateSnippet(db *sql.DB, d dialect, id int, title string, content string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	var current Revision
+
+	stmt := `SELECT id, revision, title, content, updated FROM snippets
+	WHERE expires > ` + d.now + ` AND NOT burn_after_reading AND id = ?` + d.forUpdate
+
+	err = tx.QueryRow(stmt, id).Scan(&current.SnippetID, &current.Revision,
+		&current.Title, &current.Content, &current.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revisions (snippet_id, revision, title, content, created)
+	VALUES(?, ?, ?, ?, ?)`
+
+	_, err = tx.Exec(stmt, current.SnippetID, current.Revision, current.Title,
+		current.Content, current.Created)
+	if err != nil {
+		return err
+	}
+
+	stmt = `UPDATE snippets SET title = ?, content = ?, revision = revision + 1,
+	updated = ` + d.now + ` WHERE id = ?`
+
+	_, err = tx.Exec(stmt, title, content, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// snippetRevisions returns the stored revisions of a snippet followed by
+// its current version.
+func snippetRevisions(db *sql.DB, current Snippet) ([]Revision, error) {
+	stmt := `SELECT snippet_id, revision, title, content, created
+	FROM snippet_revisions WHERE snippet_id = ? ORDER BY revision`
+
+	rows, err := db.Query(stmt, current.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []Revision
+
+	for rows.Next() {
+		var r Revision
+
+		err = rows.Scan(&r.SnippetID, &r.Revision, &r.Title, &r.Content, &r.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	revisions = append(revisions, Revision{
+		SnippetID: current.ID,
+		Revision:  current.Revision,
+		Title:     current.Title,
+		Content:   current.Content,
+		Created:   current.Updated,
+	})
+
+	return revisions, nil
 }
 
 // define a SnippetModel struct which wraps a sql.DB connection pool
+// for a MySQL database
 type SnippetModel struct {
 	DB *sql.DB
 }
 
 // define a Insert() method on SnippetModel which inserts a new snippet
-// into the database
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// into the database and returns its slug
+func (m *SnippetModel) Insert(snippet NewSnippet) (string, error) {
 	// define the SQL statement for inserting a new snippet record
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (slug, title, content, created, updated, expires,
+	burn_after_reading, user_id, language, format, visibility, password_hash)
+	VALUES(?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(),
+	DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY), ?, ?, ?, ?, ?, ?)`
+
+	// Hash the password before starting the transaction, as bcrypt is
+	// deliberately slow.
+	hash, err := passwordHash(snippet)
+	if err != nil {
+		return "", err
+	}
+
+	// The snippet and its tags are inserted in one transaction, so a
+	// snippet is never visible without its tags.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	slug, err := uniqueSlug(tx)
+	if err != nil {
+		return "", err
+	}
 
-	// use the Exec() method on the embedded DB field to execute the
-	// SQL statement. we pass in the title, content and expires values
+	// use the Exec() method on the transaction to execute the
+	// SQL statement, passing in the fields of the new snippet
 	// as parameters
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	result, err := tx.Exec(stmt, slug, snippet.Title, snippet.Content,
+		snippet.Expires, snippet.BurnAfterReading, nullInt(snippet.UserID),
+		snippet.Language, snippet.Format, snippet.Visibility, hash)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 
-	// use the LastInsertId() method to get the ID of the newly inserted record
+	// use the LastInsertId() method to get the ID of the newly inserted
+	// record, which the tags refer to
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+
+	err = insertTags(tx, mysqlDialect, int(id), snippet.Tags)
+	if err != nil {
+		return "", err
 	}
 
-	// The ID returned by LastInsertId() is of type int64,
-	// so we convert to int type before returning
-	return int(id), nil
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return slug, nil
 }
 
 // This will return a specific snippet based on its ID
 func (m *SnippetModel) Get(id int) (Snippet, error) {
 	// define the SQL statement for getting the snippet
-	stmt := `SELECT id, title, content, created, expires FROM snippets
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
 	WHERE expires > UTC_TIMESTAMP() AND id = ?`
 
 	// use the QueryRow() method on the embedded DB field to execute
@@ -59,16 +406,9 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 	// this returns a pointer to a sql.Row object
 	row := m.DB.QueryRow(stmt, id)
 
-	//initialize a new zeroed Snippet struct.
-	var s Snippet
-
-	// use row.Scan() to copy the values from each field in sql.Row
-	// to the corresponding field in the Snippet struct
-	// Notice that the arguements
-	// to row.Scan are *pointers* to the fields in the Snippet struct
-	// and the number of the arguments must be exactly the same as the number of
-	// selected columns in the SQL statement
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	// use scanSnippet() to copy the values from each field in sql.Row
+	// to the corresponding field in a new Snippet struct
+	s, err := scanSnippet(row)
 
 	if err != nil {
 		// if the query returns no rows, then row.Scan will return
@@ -81,14 +421,27 @@ func (m *SnippetModel) Get(id int) (Snippet, error) {
 		}
 	}
 
-	return s, nil
+	// Fill in the snippet's tags.
+	snippets := []Snippet{s}
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
+
+// GetBySlug returns a specific snippet based on its slug.
+func (m *SnippetModel) GetBySlug(slug string) (Snippet, error) {
+	return getSnippetBySlug(m.DB, mysqlDialect, slug)
 }
 
-// This will return the 10 most recently created snippets
+// This will return the 10 most recently created public snippets.
+// Burn-after-reading snippets are secrets, so they're never listed.
 func (m *SnippetModel) Latest() ([]Snippet, error) {
 	// Write the SQL statment we want to execute.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP()
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND ` + listed + `
 	ORDER BY id DESC LIMIT 10`
 
 	// Use the Query() method on connection pool to execute our
@@ -114,15 +467,9 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 	// the rows complete then the resultset automatically closes itself
 	// and frees up the underlying database connection.
 	for rows.Next() {
-		// Create a new zero value Snippet struct.
-		var s Snippet
-
-		// Use rows.Scan() to copy the values from each field in the row to the
-		// new Snippet struct that we created. Again, the arguments to row.Scan()
-		// must be pointers to the place you want to copy the data into, and the
-		// number of arguments must be exactly the same as the number of
-		// columns returned by your statement.
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		// Use scanSnippet() to copy the values from each field in the row
+		// to a new Snippet struct.
+		s, err := scanSnippet(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -139,7 +486,251 @@ func (m *SnippetModel) Latest() ([]Snippet, error) {
 		return nil, err
 	}
 
+	// Fetch the tags of all the snippets with a single query.
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, err
+	}
+
 	// If everything went OK then return the slice of snippets.
 	return snippets, nil
 
 }
+
+// List returns one page of snippets in the given sort order.
+func (m *SnippetModel) List(opts ListOptions) (Page, error) {
+	return listSnippets(m.DB, mysqlDialect, opts)
+}
+
+// Search uses the FULLTEXT index on the title and content columns. The query
+// is run in boolean mode with every word required and matched as a prefix,
+// so "gopher conc" finds snippets mentioning gophers and concurrency. The
+// most relevant snippets come first.
+//
+// The index leaves out short words and stopwords, so requiring one of those
+// would match nothing at all. They're searched for with LIKE instead, and
+// a query made up only of them is the same as the other backends' search.
+func (m *SnippetModel) Search(query string, page int) (SearchResults, error) {
+	stmt, args := fullTextSearchStmt(SearchTerms(query))
+	if stmt == "" {
+		return likeSearch(m.DB, mysqlDialect, query, page)
+	}
+
+	if page < 1 {
+		return SearchResults{Page: page}, nil
+	}
+
+	return searchSnippets(m.DB, stmt, args, page)
+}
+
+// fullTextSearchStmt builds the statement run by SnippetModel.Search(). It
+// returns an empty statement if none of the terms is in the FULLTEXT index.
+func fullTextSearchStmt(terms []string) (string, []any) {
+	var words, others []string
+	for _, term := range terms {
+		// Drop the characters which are operators in boolean mode.
+		word := strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`+-<>()~*"@`, r) {
+				return -1
+			}
+			return r
+		}, term)
+
+		if fullTextIndexed(word) {
+			words = append(words, "+"+word+"*")
+		} else {
+			others = append(others, term)
+		}
+	}
+
+	if len(words) == 0 {
+		return "", nil
+	}
+
+	against := strings.Join(words, " ")
+	conditions, args := likeConditions(others)
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND ` + listed + `
+	AND MATCH(title, content) AGAINST(? IN BOOLEAN MODE)` + conditions + `
+	ORDER BY MATCH(title, content) AGAINST(? IN BOOLEAN MODE) DESC, id DESC
+	LIMIT ? OFFSET ?`
+
+	args = append([]any{against}, args...)
+	args = append(args, against)
+
+	return stmt, args
+}
+
+// Tags returns up to limit of the most used tags.
+func (m *SnippetModel) Tags(limit int) ([]TagCount, error) {
+	return tagCounts(m.DB, mysqlDialect, limit)
+}
+
+// Update replaces the title and content of a snippet, keeping the previous
+// version as a revision.
+func (m *SnippetModel) Update(id int, title string, content string) error {
+	return updateSnippet(m.DB, mysqlDialect, id, title, content)
+}
+
+// Revisions returns every version of a snippet, oldest first.
+func (m *SnippetModel) Revisions(id int) ([]Revision, error) {
+	current, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return snippetRevisions(m.DB, current)
+}
+
+// Burn returns a burn-after-reading snippet and deletes it in the same
+// transaction. SELECT ... FOR UPDATE locks the row, so a concurrent Burn()
+// of the same snippet waits for us to commit and then finds nothing.
+func (m *SnippetModel) Burn(id int) (Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return Snippet{}, err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > UTC_TIMESTAMP() AND burn_after_reading AND id = ?
+	FOR UPDATE`
+
+	s, err := scanSnippet(tx.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	err = burnSnippet(tx, &s)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return s, nil
+}
+
+// burnSnippet loads the tags of a snippet which is being burned, and then
+// deletes it along with its tags and revisions. Like reapExpired(), it
+// doesn't rely on the foreign keys to cascade.
+func burnSnippet(tx *sql.Tx, s *Snippet) error {
+	snippets := []Snippet{*s}
+	err := loadTags(tx, snippets)
+	if err != nil {
+		return err
+	}
+	*s = snippets[0]
+
+	for _, stmt := range []string{
+		`DELETE FROM snippet_tags WHERE snippet_id = ?`,
+		`DELETE FROM snippet_revisions WHERE snippet_id = ?`,
+		`DELETE FROM snippets WHERE id = ?`,
+	} {
+		_, err = tx.Exec(stmt, s.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteExpired deletes up to limit snippets which expired at or before the
+// given time.
+func (m *SnippetModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	return reapExpired(m.DB, mysqlExpiredStmt, before.UTC(), limit, "")
+}
+
+// ArchiveExpired moves up to limit snippets which expired at or before the
+// given time into the snippets_archive table.
+func (m *SnippetModel) ArchiveExpired(before time.Time, limit int) (int, error) {
+	stmt := `INSERT INTO snippets_archive (id, title, content, created, expires, archived)
+	SELECT id, title, content, created, expires, UTC_TIMESTAMP() FROM snippets WHERE id IN (%s)`
+
+	return reapExpired(m.DB, mysqlExpiredStmt, before.UTC(), limit, stmt)
+}
+
+// mysqlExpiredStmt selects a batch of expired snippet IDs. FOR UPDATE locks
+// the rows, so two application instances can't reap the same batch.
+const mysqlExpiredStmt = `SELECT id FROM snippets WHERE expires <= ?
+	ORDER BY expires LIMIT ? FOR UPDATE`
+
+// reapExpired selects a batch of expired snippet IDs with selectStmt, copies
+// them elsewhere with archiveStmt (if it isn't empty), and deletes them, all
+// in one transaction. archiveStmt must contain a %s verb, which is replaced
+// with the placeholders for the IDs.
+func reapExpired(db *sql.DB, selectStmt string, before any, limit int, archiveStmt string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	rows, err := tx.Query(selectStmt, before, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []any
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	placeholders := strings.Repeat("?, ", len(ids)-1) + "?"
+
+	if archiveStmt != "" {
+		_, err = tx.Exec(fmt.Sprintf(archiveStmt, placeholders), ids...)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// The foreign key would cascade this, but SQLite only enforces foreign
+	// keys when the DSN turns them on, so we don't rely on it.
+	_, err = tx.Exec(fmt.Sprintf("DELETE FROM snippet_revisions WHERE snippet_id IN (%s)", placeholders), ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("DELETE FROM snippet_tags WHERE snippet_id IN (%s)", placeholders), ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("DELETE FROM snippets WHERE id IN (%s)", placeholders), ids...)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
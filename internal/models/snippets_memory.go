@@ -0,0 +1,410 @@
+package models
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemorySnippetModel keeps snippets in a map guarded by a mutex. It's handy
+// for running the application (and its handler tests) without a database
+// server. Nothing is persisted between restarts.
+type MemorySnippetModel struct {
+	mu        sync.RWMutex
+	snippets  map[int]Snippet
+	slugs     map[string]int
+	revisions map[int][]Revision
+	archive   []Snippet
+	nextID    int
+
+	// Now returns the current time. It defaults to time.Now but can be
+	// replaced to control expiry in tests.
+	Now func() time.Time
+}
+
+// NewMemorySnippetModel returns an empty, ready to use MemorySnippetModel.
+func NewMemorySnippetModel() *MemorySnippetModel {
+	return &MemorySnippetModel{
+		snippets:  make(map[int]Snippet),
+		slugs:     make(map[string]int),
+		revisions: make(map[int][]Revision),
+		nextID:    1,
+		Now:       time.Now,
+	}
+}
+
+func (m *MemorySnippetModel) now() time.Time {
+	// Truncate to whole seconds in UTC, to match what we get back from the
+	// DATETIME columns in the SQL backends.
+	return m.Now().UTC().Truncate(time.Second)
+}
+
+// Insert adds a new snippet which expires the given number of days from now,
+// returning its slug.
+func (m *MemorySnippetModel) Insert(snippet NewSnippet) (string, error) {
+	hash, err := passwordHash(snippet)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var slug string
+	for range maxSlugAttempts {
+		slug, err = newSlug()
+		if err != nil {
+			return "", err
+		}
+		if _, taken := m.slugs[slug]; !taken {
+			break
+		}
+		slug = ""
+	}
+	if slug == "" {
+		return "", errSlugCollision
+	}
+
+	now := m.now()
+
+	s := Snippet{
+		ID:               m.nextID,
+		Slug:             slug,
+		Title:            snippet.Title,
+		Content:          snippet.Content,
+		Created:          now,
+		Expires:          now.AddDate(0, 0, snippet.Expires),
+		BurnAfterReading: snippet.BurnAfterReading,
+		UserID:           snippet.UserID,
+		Revision:         1,
+		Updated:          now,
+		Tags:             append([]string{}, snippet.Tags...),
+		Language:         snippet.Language,
+		Format:           snippet.Format,
+		Visibility:       snippet.Visibility,
+		PasswordHash:     hash,
+	}
+
+	slices.Sort(s.Tags)
+
+	m.snippets[s.ID] = s
+	m.slugs[s.Slug] = s.ID
+	m.nextID++
+
+	return s.Slug, nil
+}
+
+// Get returns a specific, unexpired snippet based on its ID.
+func (m *MemorySnippetModel) Get(id int) (Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.Expires.After(m.now()) {
+		return Snippet{}, ErrNoRecord
+	}
+
+	return s, nil
+}
+
+// listed mirrors the condition the SQL backends use for snippets which may
+// appear in listings, searches and the tag cloud.
+func (m *MemorySnippetModel) listed(s Snippet, now time.Time) bool {
+	return s.Expires.After(now) && !s.BurnAfterReading && s.Visibility == VisibilityPublic
+}
+
+// GetBySlug returns a specific, unexpired snippet based on its slug.
+func (m *MemorySnippetModel) GetBySlug(slug string) (Snippet, error) {
+	m.mu.RLock()
+	id, ok := m.slugs[slug]
+	m.mu.RUnlock()
+
+	if !ok {
+		return Snippet{}, ErrNoRecord
+	}
+
+	return m.Get(id)
+}
+
+// Latest returns the 10 most recently created, unexpired public snippets,
+// leaving out burn-after-reading snippets.
+func (m *MemorySnippetModel) Latest() ([]Snippet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+
+	var snippets []Snippet
+	for _, s := range m.snippets {
+		if m.listed(s, now) {
+			snippets = append(snippets, s)
+		}
+	}
+
+	// Mirror the ORDER BY id DESC LIMIT 10 of the SQL backends.
+	sort.Slice(snippets, func(i, j int) bool {
+		return snippets[i].ID > snippets[j].ID
+	})
+
+	if len(snippets) > 10 {
+		snippets = snippets[:10]
+	}
+
+	return snippets, nil
+}
+
+// List returns one page of snippets in the given sort order, in the same
+// way as the SQL backends.
+func (m *MemorySnippetModel) List(opts ListOptions) (Page, error) {
+	from, backward, err := checkListOptions(opts)
+	if err != nil {
+		return Page{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+
+	// sign flips the ascending comparison when the rows run from the
+	// largest value down in the direction we're paging.
+	sign := 1
+	if sortDesc[opts.Sort] != backward {
+		sign = -1
+	}
+
+	var snippets []Snippet
+	for _, s := range m.snippets {
+		if !m.listed(s, now) {
+			continue
+		}
+		if from != nil && sign*compareSnippets(opts.Sort, s, *from) <= 0 {
+			continue
+		}
+		if opts.Tag != "" && !slices.Contains(s.Tags, opts.Tag) {
+			continue
+		}
+		snippets = append(snippets, s)
+	}
+
+	slices.SortFunc(snippets, func(a, b Snippet) int {
+		return sign * compareSnippets(opts.Sort, a, b)
+	})
+
+	if len(snippets) > opts.Limit+1 {
+		snippets = snippets[:opts.Limit+1]
+	}
+
+	return newPage(opts, snippets, backward), nil
+}
+
+// Search finds snippets whose title or content contain every term of the
+// query, ignoring case, newest first.
+func (m *MemorySnippetModel) Search(query string, page int) (SearchResults, error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 || page < 1 {
+		return SearchResults{Page: page}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+
+	var matches []Snippet
+	for _, s := range m.snippets {
+		if !m.listed(s, now) {
+			continue
+		}
+
+		title, content := strings.ToLower(s.Title), strings.ToLower(s.Content)
+
+		found := true
+		for _, term := range terms {
+			term = strings.ToLower(term)
+			if !strings.Contains(title, term) && !strings.Contains(content, term) {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			matches = append(matches, s)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].ID > matches[j].ID
+	})
+
+	results := SearchResults{Page: page}
+
+	start := (page - 1) * SearchPageSize
+	if start < len(matches) {
+		end := min(start+SearchPageSize, len(matches))
+		results.Snippets = matches[start:end]
+		results.HasNext = end < len(matches)
+	}
+
+	return results, nil
+}
+
+// Tags returns up to limit of the most used tags.
+func (m *MemorySnippetModel) Tags(limit int) ([]TagCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+
+	counts := make(map[string]int)
+	for _, s := range m.snippets {
+		if m.listed(s, now) {
+			for _, tag := range s.Tags {
+				counts[tag]++
+			}
+		}
+	}
+
+	var tags []TagCount
+	for name, count := range counts {
+		tags = append(tags, TagCount{Name: name, Count: count})
+	}
+
+	// Mirror the ORDER BY COUNT(*) DESC, t.name of the SQL backends.
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+
+	return tags, nil
+}
+
+// Update replaces the title and content of a snippet, keeping the previous
+// version as a revision.
+func (m *MemorySnippetModel) Update(id int, title string, content string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+
+	s, ok := m.snippets[id]
+	if !ok || s.BurnAfterReading || !s.Expires.After(now) {
+		return ErrNoRecord
+	}
+
+	m.revisions[id] = append(m.revisions[id], Revision{
+		SnippetID: s.ID,
+		Revision:  s.Revision,
+		Title:     s.Title,
+		Content:   s.Content,
+		Created:   s.Updated,
+	})
+
+	s.Title = title
+	s.Content = content
+	s.Revision++
+	s.Updated = now
+
+	m.snippets[id] = s
+
+	return nil
+}
+
+// Revisions returns every version of a snippet, oldest first.
+func (m *MemorySnippetModel) Revisions(id int) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.Expires.After(m.now()) {
+		return nil, ErrNoRecord
+	}
+
+	revisions := append([]Revision(nil), m.revisions[id]...)
+	revisions = append(revisions, Revision{
+		SnippetID: s.ID,
+		Revision:  s.Revision,
+		Title:     s.Title,
+		Content:   s.Content,
+		Created:   s.Updated,
+	})
+
+	return revisions, nil
+}
+
+// Burn returns a burn-after-reading snippet and deletes it. Holding the
+// write lock for both steps means only one caller can ever get it.
+func (m *MemorySnippetModel) Burn(id int) (Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.snippets[id]
+	if !ok || !s.BurnAfterReading || !s.Expires.After(m.now()) {
+		return Snippet{}, ErrNoRecord
+	}
+
+	delete(m.snippets, id)
+	delete(m.slugs, s.Slug)
+
+	return s, nil
+}
+
+// DeleteExpired deletes up to limit snippets which expired at or before the
+// given time.
+func (m *MemorySnippetModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	return m.reapExpired(before, limit, false), nil
+}
+
+// ArchiveExpired moves up to limit snippets which expired at or before the
+// given time into the in-memory archive.
+func (m *MemorySnippetModel) ArchiveExpired(before time.Time, limit int) (int, error) {
+	return m.reapExpired(before, limit, true), nil
+}
+
+// Archived returns a copy of the archived snippets.
+func (m *MemorySnippetModel) Archived() []Snippet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]Snippet(nil), m.archive...)
+}
+
+func (m *MemorySnippetModel) reapExpired(before time.Time, limit int, archive bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired []Snippet
+	for _, s := range m.snippets {
+		if !s.Expires.After(before) {
+			expired = append(expired, s)
+		}
+	}
+
+	// Reap the longest-expired snippets first, like the SQL backends.
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].Expires.Before(expired[j].Expires)
+	})
+
+	if len(expired) > limit {
+		expired = expired[:limit]
+	}
+
+	for _, s := range expired {
+		if archive {
+			m.archive = append(m.archive, s)
+		}
+		delete(m.snippets, s.ID)
+		delete(m.slugs, s.Slug)
+		delete(m.revisions, s.ID)
+	}
+
+	return len(expired)
+}
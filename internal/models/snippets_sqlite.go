@@ -0,0 +1,265 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// SQLiteSnippetModel is the SQLite flavour of SnippetModel. SQLite doesn't
+// have UTC_TIMESTAMP() or DATE_ADD(), so the statements use datetime()
+// instead, but otherwise it behaves exactly the same.
+type SQLiteSnippetModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new snippet which expires the given number of days from now,
+// returning its slug.
+func (m *SQLiteSnippetModel) Insert(snippet NewSnippet) (string, error) {
+	stmt := `INSERT INTO snippets (slug, title, content, created, updated, expires,
+	burn_after_reading, user_id, language, format, visibility, password_hash)
+	VALUES(?, ?, ?, datetime('now'), datetime('now'),
+	datetime('now', '+' || ? || ' days'), ?, ?, ?, ?, ?, ?)`
+
+	hash, err := passwordHash(snippet)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	slug, err := uniqueSlug(tx)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := tx.Exec(stmt, slug, snippet.Title, snippet.Content,
+		snippet.Expires, snippet.BurnAfterReading, nullInt(snippet.UserID),
+		snippet.Language, snippet.Format, snippet.Visibility, hash)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+
+	err = insertTags(tx, sqliteDialect, int(id), snippet.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return slug, nil
+}
+
+// Get returns a specific, unexpired snippet based on its ID.
+func (m *SQLiteSnippetModel) Get(id int) (Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > datetime('now') AND id = ?`
+
+	s, err := scanSnippet(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+
+	snippets := []Snippet{s}
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return snippets[0], nil
+}
+
+// GetBySlug returns a specific, unexpired snippet based on its slug.
+func (m *SQLiteSnippetModel) GetBySlug(slug string) (Snippet, error) {
+	return getSnippetBySlug(m.DB, sqliteDialect, slug)
+}
+
+// Latest returns the 10 most recently created, unexpired public snippets,
+// leaving out burn-after-reading snippets.
+func (m *SQLiteSnippetModel) Latest() ([]Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM snippets
+	WHERE expires > datetime('now') AND ` + listed + `
+	ORDER BY id DESC LIMIT 10`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []Snippet
+
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// List returns one page of snippets in the given sort order.
+func (m *SQLiteSnippetModel) List(opts ListOptions) (Page, error) {
+	return listSnippets(m.DB, sqliteDialect, opts)
+}
+
+// Search finds snippets with LIKE, as SQLite has no FULLTEXT indexes. It's
+// case-insensitive for ASCII letters only.
+func (m *SQLiteSnippetModel) Search(query string, page int) (SearchResults, error) {
+	return likeSearch(m.DB, sqliteDialect, query, page)
+}
+
+// Tags returns up to limit of the most used tags.
+func (m *SQLiteSnippetModel) Tags(limit int) ([]TagCount, error) {
+	return tagCounts(m.DB, sqliteDialect, limit)
+}
+
+// Update replaces the title and content of a snippet, keeping the previous
+// version as a revision.
+func (m *SQLiteSnippetModel) Update(id int, title string, content string) error {
+	return updateSnippet(m.DB, sqliteDialect, id, title, content)
+}
+
+// Revisions returns every version of a snippet, oldest first.
+func (m *SQLiteSnippetModel) Revisions(id int) ([]Revision, error) {
+	current, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return snippetRevisions(m.DB, current)
+}
+
+// Burn returns a burn-after-reading snippet and deletes it. SQLite supports
+// DELETE ... RETURNING, so the read and the delete are a single statement
+// and two concurrent readers can't both get the snippet.
+//
+// The transaction is started with BEGIN IMMEDIATE, which takes the write
+// lock straight away. A plain BEGIN would take it at the DELETE, after
+// reading the tags, and two burns upgrading their read locks at the same
+// time fail with SQLITE_BUSY instead of one of them waiting and then
+// finding nothing to burn.
+func (m *SQLiteSnippetModel) Burn(id int) (Snippet, error) {
+	ctx := context.Background()
+
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return Snippet{}, err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `BEGIN IMMEDIATE`)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	s, err := burnSQLite(ctx, conn, id)
+	if err != nil {
+		conn.ExecContext(ctx, `ROLLBACK`)
+		return Snippet{}, err
+	}
+
+	_, err = conn.ExecContext(ctx, `COMMIT`)
+	if err != nil {
+		conn.ExecContext(ctx, `ROLLBACK`)
+		return Snippet{}, err
+	}
+
+	return s, nil
+}
+
+// burnSQLite does the work of Burn() inside its transaction.
+func burnSQLite(ctx context.Context, conn *sql.Conn, id int) (Snippet, error) {
+	// Read the tags first, because deleting the snippet can cascade to
+	// them.
+	tagged := []Snippet{{ID: id}}
+	err := loadTags(connQueryer{ctx, conn}, tagged)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	stmt := `DELETE FROM snippets
+	WHERE expires > datetime('now') AND burn_after_reading AND id = ?
+	RETURNING ` + snippetColumns
+
+	s, err := scanSnippet(conn.QueryRowContext(ctx, stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Snippet{}, ErrNoRecord
+		}
+		return Snippet{}, err
+	}
+	s.Tags = tagged[0].Tags
+
+	_, err = conn.ExecContext(ctx, `DELETE FROM snippet_tags WHERE snippet_id = ?`, id)
+	if err != nil {
+		return Snippet{}, err
+	}
+
+	return s, nil
+}
+
+// connQueryer lets a *sql.Conn, which only has QueryContext(), be used as a
+// queryer.
+type connQueryer struct {
+	ctx  context.Context
+	conn *sql.Conn
+}
+
+func (q connQueryer) Query(query string, args ...any) (*sql.Rows, error) {
+	return q.conn.QueryContext(q.ctx, query, args...)
+}
+
+// sqliteTime formats t the same way as SQLite's datetime() function, so
+// that it compares correctly with the values stored in DATETIME columns.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// sqliteExpiredStmt selects a batch of expired snippet IDs.
+const sqliteExpiredStmt = `SELECT id FROM snippets WHERE expires <= ?
+	ORDER BY expires LIMIT ?`
+
+// DeleteExpired deletes up to limit snippets which expired at or before the
+// given time.
+func (m *SQLiteSnippetModel) DeleteExpired(before time.Time, limit int) (int, error) {
+	return reapExpired(m.DB, sqliteExpiredStmt, sqliteTime(before), limit, "")
+}
+
+// ArchiveExpired moves up to limit snippets which expired at or before the
+// given time into the snippets_archive table.
+func (m *SQLiteSnippetModel) ArchiveExpired(before time.Time, limit int) (int, error) {
+	stmt := `INSERT INTO snippets_archive (id, title, content, created, expires, archived)
+	SELECT id, title, content, created, expires, datetime('now') FROM snippets WHERE id IN (%s)`
+
+	return reapExpired(m.DB, sqliteExpiredStmt, sqliteTime(before), limit, stmt)
+}
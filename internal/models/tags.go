@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// TagCount is a tag along with the number of listed snippets which have it.
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// insertTags links a snippet to the given tags, creating any tags which
+// don't exist yet. It runs inside the transaction which inserts the snippet.
+func insertTags(tx *sql.Tx, d dialect, snippetID int, tags []string) error {
+	for _, tag := range tags {
+		_, err := tx.Exec(d.insertIgnore+` INTO tags (name) VALUES (?)`, tag)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT INTO snippet_tags (snippet_id, tag_id)
+		SELECT ?, id FROM tags WHERE name = ?`
+
+		_, err = tx.Exec(stmt, snippetID, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadTags fills in the Tags field of every snippet in the slice. It uses a
+// single query however many snippets there are, rather than one query per
+// snippet.
+func loadTags(q queryer, snippets []Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(snippets))
+	ids := make([]any, len(snippets))
+	for i, s := range snippets {
+		index[s.ID] = i
+		ids[i] = s.ID
+		// Use an empty slice rather than nil, so that the JSON API
+		// returns [] for snippets without tags.
+		snippets[i].Tags = []string{}
+	}
+
+	stmt := `SELECT st.snippet_id, t.name FROM snippet_tags st
+	INNER JOIN tags t ON t.id = st.tag_id
+	WHERE st.snippet_id IN (` + strings.Repeat("?, ", len(ids)-1) + `?)
+	ORDER BY t.name`
+
+	rows, err := q.Query(stmt, ids...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int
+			name string
+		)
+
+		err = rows.Scan(&id, &name)
+		if err != nil {
+			return err
+		}
+
+		i := index[id]
+		snippets[i].Tags = append(snippets[i].Tags, name)
+	}
+
+	return rows.Err()
+}
+
+// tagCounts returns up to limit of the most used tags, counting only the
+// snippets which are listed (so not expired, burn-after-reading or
+// non-public ones).
+func tagCounts(db *sql.DB, d dialect, limit int) ([]TagCount, error) {
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+	INNER JOIN snippet_tags st ON st.tag_id = t.id
+	INNER JOIN snippets s ON s.id = st.snippet_id
+	WHERE s.expires > ` + d.now + ` AND NOT s.burn_after_reading
+	AND s.visibility = 'public'
+	GROUP BY t.name
+	ORDER BY COUNT(*) DESC, t.name
+	LIMIT ?`
+
+	rows, err := db.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []TagCount
+
+	for rows.Next() {
+		var tc TagCount
+
+		err = rows.Scan(&tc.Name, &tc.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts = append(counts, tc)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
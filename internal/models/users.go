@@ -0,0 +1,135 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define a new User struct. Notice how the field names and types align
+// with the columns in the database "users" table?
+type User struct {
+	ID             int
+	Name           string
+	Email          string
+	HashedPassword []byte
+	Created        time.Time
+}
+
+// UserStore describes the methods our handlers need from a user backend.
+// Like SnippetStore, it's implemented for MySQL, SQLite and in memory.
+type UserStore interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Exists(id int) (bool, error)
+}
+
+// Define a new UserModel struct which wraps a MySQL database connection pool.
+type UserModel struct {
+	DB *sql.DB
+}
+
+// MaxPasswordBytes is the longest password bcrypt can hash. Forms check it
+// so that people get a field error rather than a failed signup.
+const MaxPasswordBytes = 72
+
+// hashPassword creates a bcrypt hash of the plain-text password. A cost of
+// 12 takes a few hundred milliseconds, which is slow enough to make offline
+// guessing expensive without making login sluggish.
+func hashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// checkPassword compares a bcrypt hash with a plain-text password, mapping a
+// mismatch to ErrInvalidCredentials. A password too long for bcrypt can't
+// match any hash, so it's a mismatch too, not an error.
+func checkPassword(hashedPassword []byte, password string) error {
+	if len(password) > MaxPasswordBytes {
+		return ErrInvalidCredentials
+	}
+
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return nil
+}
+
+// We'll use the Insert method to add a new record to the "users" table.
+func (m *UserModel) Insert(name, email, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	// Use the Exec() method to insert the user details and hashed password
+	// into the users table.
+	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	if err != nil {
+		// If this returns an error, we use the errors.As() function to check
+		// whether the error has the type *mysql.MySQLError. If it does, the
+		// error will be assigned to the mySQLError variable. We can then check
+		// whether or not the error relates to our users_uc_email key by
+		// checking if the error code equals 1062 and the contents of the error
+		// message string. If it does, we return an ErrDuplicateEmail error.
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "users_uc_email") {
+				return ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// We'll use the Authenticate method to verify whether a user exists with
+// the provided email address and password. This will return the relevant
+// user ID if they do.
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	// Retrieve the id and hashed password associated with the given email. If
+	// no matching email exists we return the ErrInvalidCredentials error.
+	var id int
+	var hashedPassword []byte
+
+	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	// Check whether the hashed password and plain-text password provided match.
+	err = checkPassword(hashedPassword, password)
+	if err != nil {
+		return 0, err
+	}
+
+	// Otherwise, the password is correct. Return the user ID.
+	return id, nil
+}
+
+// We'll use the Exists method to check if a user exists with a specific ID.
+func (m *UserModel) Exists(id int) (bool, error) {
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryUserModel keeps users in memory. Emails are unique, just like the
+// users_uc_email constraint in the SQL backends.
+type MemoryUserModel struct {
+	mu      sync.RWMutex
+	users   map[int]User
+	byEmail map[string]int
+	nextID  int
+}
+
+// NewMemoryUserModel returns an empty, ready to use MemoryUserModel.
+func NewMemoryUserModel() *MemoryUserModel {
+	return &MemoryUserModel{
+		users:   make(map[int]User),
+		byEmail: make(map[string]int),
+		nextID:  1,
+	}
+}
+
+// Insert adds a new user, storing a bcrypt hash of their password.
+func (m *MemoryUserModel) Insert(name, email, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.byEmail[email]; exists {
+		return ErrDuplicateEmail
+	}
+
+	u := User{
+		ID:             m.nextID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC().Truncate(time.Second),
+	}
+
+	m.users[u.ID] = u
+	m.byEmail[email] = u.ID
+	m.nextID++
+
+	return nil
+}
+
+// Authenticate returns the ID of the user with the given email address and
+// password, or ErrInvalidCredentials if they don't match.
+func (m *MemoryUserModel) Authenticate(email, password string) (int, error) {
+	m.mu.RLock()
+	id, ok := m.byEmail[email]
+	u := m.users[id]
+	m.mu.RUnlock()
+
+	if !ok {
+		return 0, ErrInvalidCredentials
+	}
+
+	err := checkPassword(u.HashedPassword, password)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Exists reports whether a user with the given ID exists.
+func (m *MemoryUserModel) Exists(id int) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.users[id]
+	return ok, nil
+}
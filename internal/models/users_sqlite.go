@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteUserModel is the SQLite flavour of UserModel.
+type SQLiteUserModel struct {
+	DB *sql.DB
+}
+
+// Insert adds a new user, storing a bcrypt hash of their password.
+func (m *SQLiteUserModel) Insert(name, email, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	stmt := `INSERT INTO users (name, email, hashed_password, created)
+	VALUES(?, ?, ?, datetime('now'))`
+
+	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
+	if err != nil {
+		// The users table only has the one UNIQUE constraint (on email), so
+		// any unique constraint violation means the email is taken.
+		var sqliteError *sqlite.Error
+		if errors.As(err, &sqliteError) && sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+			return ErrDuplicateEmail
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Authenticate returns the ID of the user with the given email address and
+// password, or ErrInvalidCredentials if they don't match.
+func (m *SQLiteUserModel) Authenticate(email, password string) (int, error) {
+	var id int
+	var hashedPassword []byte
+
+	stmt := "SELECT id, hashed_password FROM users WHERE email = ?"
+
+	err := m.DB.QueryRow(stmt, email).Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	err = checkPassword(hashedPassword, password)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Exists reports whether a user with the given ID exists.
+func (m *SQLiteUserModel) Exists(id int) (bool, error) {
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryBucket is a bucket along with when it's full again, so that Evict()
+// doesn't need to know which limit it belongs to.
+type memoryBucket struct {
+	Bucket
+	fullAt time.Time
+}
+
+// MemoryStore keeps buckets in a map guarded by a mutex.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+// NewMemoryStore returns an empty, ready to use MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take takes a token from the bucket for key, creating it if needed.
+func (s *MemoryStore) Take(key string, l Limit, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{}
+		s.buckets[key] = b
+	}
+
+	allowed, retryAfter := b.Take(l, now)
+	b.fullAt = b.FullAt(l)
+
+	return allowed, retryAfter, nil
+}
+
+// Evict forgets the buckets which are full again at now.
+func (s *MemoryStore) Evict(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for key, b := range s.buckets {
+		if !b.fullAt.After(now) {
+			delete(s.buckets, key)
+			n++
+		}
+	}
+
+	return n, nil
+}
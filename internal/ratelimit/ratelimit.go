@@ -0,0 +1,121 @@
+// Package ratelimit implements token bucket rate limiting. Each key (a
+// client IP or user, say) has a bucket holding up to Limit.Burst tokens,
+// which refills at Limit.Rate tokens a second. Every request takes a token,
+// and requests which find the bucket empty are turned away.
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is the size and refill rate of a bucket. The zero Limit disables
+// rate limiting.
+type Limit struct {
+	// Rate is the number of tokens added every second.
+	Rate float64
+	// Burst is the most tokens a bucket can hold.
+	Burst int
+}
+
+// Every returns a Limit which allows n requests per period, all at once if
+// the bucket is full.
+func Every(n int, period time.Duration) Limit {
+	if n <= 0 || period <= 0 {
+		return Limit{}
+	}
+	return Limit{Rate: float64(n) / period.Seconds(), Burst: n}
+}
+
+// Enabled reports whether l limits anything.
+func (l Limit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// String formats l the way Set() parses it, e.g. "60/1m0s".
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+
+	period := time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+	return fmt.Sprintf("%d/%s", l.Burst, period.Round(time.Millisecond))
+}
+
+// Set parses a limit written as "requests/period", like "20/1m" for twenty
+// requests a minute, or "off". It makes *Limit a flag.Value.
+func (l *Limit) Set(s string) error {
+	if s == "off" || s == "0" {
+		*l = Limit{}
+		return nil
+	}
+
+	count, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return fmt.Errorf("ratelimit: %q isn't of the form requests/period", s)
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return fmt.Errorf("ratelimit: invalid number of requests in %q", s)
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("ratelimit: invalid period in %q", s)
+	}
+
+	*l = Every(n, d)
+	return nil
+}
+
+// Bucket is the state of one key's bucket. The zero Bucket is full.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills the bucket for the time since it was last updated and then
+// tries to take a token from it. If the bucket is empty, it returns false
+// and how long until there's a token again.
+func (b *Bucket) Take(l Limit, now time.Time) (bool, time.Duration) {
+	if b.Updated.IsZero() {
+		b.Tokens = float64(l.Burst)
+	} else if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens = min(float64(l.Burst), b.Tokens+elapsed.Seconds()*l.Rate)
+	}
+
+	if now.After(b.Updated) {
+		b.Updated = now
+	}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+
+	wait := (1 - b.Tokens) / l.Rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// FullAt returns when the bucket will be full again. From then on it's
+// no different from a new bucket, so it can be forgotten.
+func (b Bucket) FullAt(l Limit) time.Time {
+	missing := float64(l.Burst) - b.Tokens
+	return b.Updated.Add(time.Duration(missing / l.Rate * float64(time.Second)))
+}
+
+// Store keeps the buckets. MemoryStore keeps them in the process, which is
+// enough for a single instance. Several instances behind a load balancer
+// need a shared store, like the database-backed ones in the models package,
+// so that they all see the same buckets.
+type Store interface {
+	// Take takes a token from the bucket for key, as Bucket.Take does.
+	Take(key string, l Limit, now time.Time) (bool, time.Duration, error)
+
+	// Evict forgets the buckets which are full again at now, returning
+	// how many it removed.
+	Evict(now time.Time) (int, error)
+}
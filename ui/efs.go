@@ -0,0 +1,13 @@
+package ui
+
+import (
+	"embed"
+)
+
+// Files holds the HTML templates and static assets, embedded into the
+// binary so that it doesn't matter which directory the application is
+// started from. The paths are relative to this directory, so the templates
+// live under "html/" and the assets under "static/".
+//
+//go:embed "html" "static"
+var Files embed.FS